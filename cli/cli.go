@@ -5,8 +5,11 @@ import (
 	"encoding/gob"
 	"log"
 	"net"
+	"net/url"
+	"strings"
 
 	"github.com/karlek/nyfiken/ini"
+	"github.com/karlek/nyfiken/jar"
 	"github.com/karlek/nyfiken/page"
 	"github.com/karlek/nyfiken/settings"
 	"github.com/mewkiz/pkg/bufioutil"
@@ -60,14 +63,53 @@ func takeInput(conn net.Conn) (err error) {
 			return errutil.Err(err)
 		}
 
+		// Clear the cookie jar named in the query, and reply with the error
+		// message of a failure; empty on success.
+		if strings.HasPrefix(query, settings.QueryClearCookies) {
+			name := strings.TrimSpace(strings.TrimPrefix(query, settings.QueryClearCookies))
+			var reply string
+			if clearErr := jar.Clear(name); clearErr != nil {
+				log.Println(errutil.Err(clearErr))
+				reply = clearErr.Error()
+			}
+			// Encode (send) the value.
+			err = gob.NewEncoder(conn).Encode(reply)
+			if err != nil {
+				return errutil.Err(err)
+			}
+			continue
+		}
+
 		// Do something with the query
 		switch query {
 		case settings.QueryUpdates:
 			// Encode (send) the value.
-			err = gob.NewEncoder(conn).Encode(settings.Updates)
+			err = gob.NewEncoder(conn).Encode(settings.CopyUpdates())
+		case settings.QueryErrors:
+			// Encode (send) the value.
+			err = gob.NewEncoder(conn).Encode(settings.CopyErrors())
+		case settings.QueryCookies:
+			cookies, err := jar.All()
+			if err != nil {
+				return errutil.Err(err)
+			}
+			// Encode (send) the value.
+			err = gob.NewEncoder(conn).Encode(cookies)
+			if err != nil {
+				return errutil.Err(err)
+			}
+		case settings.QueryCacheNames:
+			names, err := cacheNames(settings.CopyUpdates())
+			if err != nil {
+				return errutil.Err(err)
+			}
+			// Encode (send) the value.
+			err = gob.NewEncoder(conn).Encode(names)
+			if err != nil {
+				return errutil.Err(err)
+			}
 		case settings.QueryClearAll:
-			settings.Updates = make(map[string]bool)
-			err = settings.SaveUpdates()
+			err = settings.ClearUpdates()
 		case settings.QueryForceRecheck:
 			pages, err := ini.ReadPages(settings.PagesPath)
 			if err != nil {
@@ -81,3 +123,35 @@ func takeInput(conn net.Conn) (err error) {
 	}
 	return nil
 }
+
+// cacheNames returns the cache names of the updated pages. Pages with a
+// request method or body have their own cache names, so the pages file is
+// consulted; URLs not found in it fall back to the plain URL cache name. The
+// pages are read by the daemon, since their secrets may only be resolvable in
+// its environment.
+func cacheNames(ups map[string]bool) (names []string, err error) {
+	pages, err := ini.ReadPages(settings.PagesPath)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+
+	for up := range ups {
+		found := false
+		for _, p := range pages {
+			if p.ReqUrl.String() == up {
+				names = append(names, p.UrlAsFilename())
+				found = true
+			}
+		}
+		if found {
+			continue
+		}
+
+		u, err := url.Parse(up)
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+		names = append(names, u.Host+u.Path+u.RawQuery)
+	}
+	return names, nil
+}
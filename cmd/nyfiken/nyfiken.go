@@ -8,13 +8,15 @@ import (
 	"io"
 	"log"
 	"net"
-	"net/url"
+	"net/http"
 	"os"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/karlek/nyfiken/filename"
 	"github.com/karlek/nyfiken/ini"
+	"github.com/karlek/nyfiken/page"
 	"github.com/karlek/nyfiken/settings"
 	"github.com/mewkiz/pkg/bufioutil"
 	"github.com/mewkiz/pkg/errutil"
@@ -29,12 +31,18 @@ var flagRecheck bool
 var flagClearAll bool
 var flagReadAll bool
 var flagReadAndClearAll bool
+var flagErrors bool
+var flagCookies bool
+var flagClearCookies string
 
 func init() {
 	flag.BoolVar(&flagRecheck, "f", false, "forces a recheck.")
 	flag.BoolVar(&flagReadAll, "r", false, "read all updated pages in your browser.")
 	flag.BoolVar(&flagClearAll, "c", false, "will clear list of updated sites.")
 	flag.BoolVar(&flagReadAndClearAll, "rc", false, "read all updated pages in your browser and clear the list of updated sites.")
+	flag.BoolVar(&flagErrors, "e", false, "list pages whose last check failed.")
+	flag.BoolVar(&flagCookies, "j", false, "list the cookies of all cookie jars.")
+	flag.StringVar(&flagClearCookies, "jc", "", "clear the cookie jar by the provided name; `all` clears all cookie jars.")
 	flag.Usage = usage
 }
 
@@ -72,10 +80,22 @@ func nyfikenc() (err error) {
 	if flagRecheck ||
 		flagClearAll ||
 		flagReadAndClearAll ||
-		flagReadAll {
+		flagReadAll ||
+		flagErrors ||
+		flagCookies ||
+		flagClearCookies != "" {
 		if flagRecheck {
 			return force(&bw)
 		}
+		if flagErrors {
+			return listErrors(&bw, conn)
+		}
+		if flagCookies {
+			return listCookies(&bw, conn)
+		}
+		if flagClearCookies != "" {
+			return clearCookies(&bw, conn, flagClearCookies)
+		}
 		if flagClearAll {
 			return clearAll(&bw, conn)
 		}
@@ -113,8 +133,8 @@ func nyfikenc() (err error) {
 
 // Opens all links with browser.
 func readAll(bw *bufioutil.Writer, conn net.Conn) (err error) {
-	// Read in config file to settings.Global
-	err = ini.ReadSettings(settings.ConfigPath)
+	// Read in the settings section of the config file to settings.Global.
+	err = ini.ReadClientSettings(settings.ConfigPath)
 	if err != nil {
 		return errutil.Err(err)
 	}
@@ -138,8 +158,16 @@ func readAll(bw *bufioutil.Writer, conn net.Conn) (err error) {
 	var arguments []string
 	// Loop through all updates and open them with the browser
 	for up := range ups {
+		// The output of commands can't be opened in a browser.
+		if strings.HasPrefix(up, page.SchemeExec+":") {
+			fmt.Println(up)
+			continue
+		}
 		arguments = append(arguments, up)
 	}
+	if len(arguments) == 0 {
+		return nil
+	}
 	cmd := exec.Command(settings.Global.Browser, arguments...)
 	err = cmd.Start()
 	if err != nil {
@@ -173,13 +201,11 @@ func clearAll(bw *bufioutil.Writer, conn net.Conn) (err error) {
 			fmt.Println("Unknown command. Please enter y or n.")
 		}
 	}
-	for up := range ups {
-		u, err := url.Parse(up)
-		if err != nil {
-			return errutil.Err(err)
-		}
-
-		urlAsFilename := u.Host + u.Path + u.RawQuery
+	names, err := getCacheNames(bw, conn)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	for _, urlAsFilename := range names {
 		fname, err := filename.Encode(urlAsFilename)
 		if err != nil {
 			return errutil.Err(err)
@@ -241,6 +267,93 @@ func force(bw *bufioutil.Writer) (err error) {
 	return nil
 }
 
+// Lists pages whose last check failed, e.g. because the response was too
+// large.
+func listErrors(bw *bufioutil.Writer, conn net.Conn) (err error) {
+	// Ask for errors.
+	_, err = bw.WriteLine(settings.QueryErrors)
+	if err != nil {
+		return errutil.Err(err)
+	}
+
+	// Decode (receive) the value.
+	var errs map[string]string
+	err = gob.NewDecoder(conn).Decode(&errs)
+	if err != nil {
+		return errutil.Err(err)
+	}
+
+	if len(errs) == 0 {
+		fmt.Println("All pages were checked successfully.")
+		return nil
+	}
+	for u, msg := range errs {
+		fmt.Printf("%s\n\t%s\n", u, msg)
+	}
+	return nil
+}
+
+// Lists the cookies of all cookie jars.
+func listCookies(bw *bufioutil.Writer, conn net.Conn) (err error) {
+	// Ask for cookies.
+	_, err = bw.WriteLine(settings.QueryCookies)
+	if err != nil {
+		return errutil.Err(err)
+	}
+
+	// Decode (receive) the value.
+	var jars map[string][]*http.Cookie
+	err = gob.NewDecoder(conn).Decode(&jars)
+	if err != nil {
+		return errutil.Err(err)
+	}
+
+	if len(jars) == 0 {
+		fmt.Println("No cookie jars.")
+		return nil
+	}
+	for name, cookies := range jars {
+		fmt.Printf("%s\n", name)
+		for _, c := range cookies {
+			expires := "session"
+			if !c.Expires.IsZero() {
+				expires = c.Expires.Format(time.RFC3339)
+			}
+			fmt.Printf("\t%s%s\t%s=%s\t%s\n", c.Domain, c.Path, c.Name, c.Value, expires)
+		}
+	}
+	return nil
+}
+
+// Clears the cookie jar by the provided name, or all cookie jars if the name is
+// `all`.
+func clearCookies(bw *bufioutil.Writer, conn net.Conn, name string) (err error) {
+	if name == "all" {
+		name = ""
+	}
+	_, err = bw.WriteLine(settings.QueryClearCookies + " " + name)
+	if err != nil {
+		return errutil.Err(err)
+	}
+
+	// Decode (receive) the reply; the error message of a failure.
+	var reply string
+	err = gob.NewDecoder(conn).Decode(&reply)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	if reply != "" {
+		return errutil.NewNoPos(reply)
+	}
+
+	if name == "" {
+		fmt.Println("All cookie jars have been cleared.")
+	} else {
+		fmt.Printf("Cookie jar %q has been cleared.\n", name)
+	}
+	return nil
+}
+
 // Receive updates from nyfikend.
 func getUpdates(bw *bufioutil.Writer, conn net.Conn) (ups map[string]bool, err error) {
 	// Ask for updates.
@@ -259,3 +372,19 @@ func getUpdates(bw *bufioutil.Writer, conn net.Conn) (ups map[string]bool, err e
 	}
 	return ups, nil
 }
+
+// Receive the cache names of the updated pages from nyfikend.
+func getCacheNames(bw *bufioutil.Writer, conn net.Conn) (names []string, err error) {
+	// Ask for cache names.
+	_, err = bw.WriteLine(settings.QueryCacheNames)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+
+	// Decode (receive) the value.
+	err = gob.NewDecoder(conn).Decode(&names)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	return names, nil
+}
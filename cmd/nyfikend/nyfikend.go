@@ -17,6 +17,7 @@ import (
 	"github.com/karlek/nyfiken/cli"
 	"github.com/karlek/nyfiken/filename"
 	"github.com/karlek/nyfiken/ini"
+	"github.com/karlek/nyfiken/notify"
 	"github.com/karlek/nyfiken/page"
 	"github.com/karlek/nyfiken/settings"
 	"github.com/mewkiz/pkg/errutil"
@@ -79,6 +80,12 @@ func nyfikend() (err error) {
 	// Listen for nyfikenc queries.
 	go cli.Listen()
 
+	// Send mail digests when they are due.
+	go sendDigests()
+
+	// Deliver notifications of the outbox, retrying failed ones.
+	go notify.RunOutbox()
+
 	var secondsElapsed float64
 	for ; ; secondsElapsed++ {
 		// A channel in which errors are sent from p.Check().
@@ -113,6 +120,16 @@ func nyfikend() (err error) {
 	return nil
 }
 
+// sendDigests sends the mail digests which are due, once a minute.
+func sendDigests() {
+	for now := range time.Tick(1 * time.Minute) {
+		err := notify.SendDigests(now)
+		if err != nil {
+			log.Println(errutil.Err(err))
+		}
+	}
+}
+
 // Reads config files only when they are modified.
 func errWrapWatchConfig(watcher *fsnotify.Watcher) {
 	err := watchConfig(watcher)
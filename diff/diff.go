@@ -0,0 +1,252 @@
+// Package diff finds the differences between two checks of a page, line by
+// line.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Kinds of lines in a diff.
+const (
+	Equal  = ' '
+	Insert = '+'
+	Delete = '-'
+)
+
+// A Line is a line of a diff.
+type Line struct {
+	Kind byte   // Equal, Insert or Delete.
+	Text string // Text of the line, without newline.
+}
+
+// String returns the line prefixed with its kind, e.g. `+ text`. Long lines are
+// shortened.
+func (line Line) String() string {
+	text := []rune(line.Text)
+	if len(text) > maxLineLen {
+		text = append(text[:maxLineLen], []rune("...")...)
+	}
+	return string(line.Kind) + " " + string(text)
+}
+
+// IsInsert reports whether the line was inserted.
+func (line Line) IsInsert() bool {
+	return line.Kind == Insert
+}
+
+// IsDelete reports whether the line was deleted.
+func (line Line) IsDelete() bool {
+	return line.Kind == Delete
+}
+
+// A Hunk is a group of changed lines surrounded by unchanged context lines.
+type Hunk struct {
+	OldStart, OldLines int // Range of the hunk in a; lines are numbered from 1.
+	NewStart, NewLines int // Range of the hunk in b.
+	Lines              []Line
+}
+
+// Header returns the range of the hunk in the unified diff format, e.g.
+// `@@ -1,4 +1,5 @@`.
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+}
+
+// Maximum number of line pairs to compare; larger differences are reported as
+// all lines of a being replaced by all lines of b.
+const maxPairs = 1 << 22
+
+// Maximum length in runes of the lines of an excerpt.
+const maxLineLen = 200
+
+// Lines returns the differences between the lines of a and b, based on their
+// shortest edit script. The differences are computed using Myers' algorithm in
+// linear space, so they may be computed once per update and reused by each
+// rendering, e.g. by Excerpt, Hunks and Unified.
+func Lines(a, b string) (lines []Line) {
+	return appendLines(nil, split(a), split(b))
+}
+
+// appendLines appends the differences between as and bs to lines. The middle
+// snake of their shortest edit script splits them into two smaller problems,
+// which are solved recursively.
+func appendLines(lines []Line, as, bs []string) []Line {
+	// Skip the common prefix and suffix.
+	var prefix int
+	for prefix < len(as) && prefix < len(bs) && as[prefix] == bs[prefix] {
+		lines = append(lines, Line{Equal, as[prefix]})
+		prefix++
+	}
+	as, bs = as[prefix:], bs[prefix:]
+	var suffix int
+	for suffix < len(as) && suffix < len(bs) && as[len(as)-1-suffix] == bs[len(bs)-1-suffix] {
+		suffix++
+	}
+	common := as[len(as)-suffix:]
+	as, bs = as[:len(as)-suffix], bs[:len(bs)-suffix]
+
+	if len(as) == 0 || len(bs) == 0 || len(as)*len(bs) > maxPairs {
+		for _, line := range as {
+			lines = append(lines, Line{Delete, line})
+		}
+		for _, line := range bs {
+			lines = append(lines, Line{Insert, line})
+		}
+	} else {
+		x, y, u, v := middleSnake(as, bs)
+		lines = appendLines(lines, as[:x], bs[:y])
+		for _, line := range as[x:u] {
+			lines = append(lines, Line{Equal, line})
+		}
+		lines = appendLines(lines, as[u:], bs[v:])
+	}
+
+	for _, line := range common {
+		lines = append(lines, Line{Equal, line})
+	}
+	return lines
+}
+
+// middleSnake returns the middle snake of the shortest edit script of as and
+// bs, i.e. the run of equal lines from as[x:u] and bs[y:v] which the edit
+// script passes halfway through its changes. The script is searched for from
+// both ends at once, until the searches overlap.
+func middleSnake(as, bs []string) (x, y, u, v int) {
+	n, m := len(as), len(bs)
+	max := (n + m + 1) / 2
+	delta := n - m
+	odd := delta%2 != 0
+
+	// forward[off+k] is the furthest x reached on diagonal k = x-y from the
+	// start; backward[off+k] is the furthest x reached on diagonal k from the
+	// end, in the reversed sequences.
+	off := max + 1
+	forward := make([]int, 2*max+3)
+	backward := make([]int, 2*max+3)
+	for d := 0; d <= max; d++ {
+		for k := -d; k <= d; k += 2 {
+			if k == -d || (k != d && forward[off+k-1] < forward[off+k+1]) {
+				x = forward[off+k+1]
+			} else {
+				x = forward[off+k-1] + 1
+			}
+			y = x - k
+			u, v = x, y
+			for u < n && v < m && as[u] == bs[v] {
+				u++
+				v++
+			}
+			forward[off+k] = u
+			if odd && delta-k >= -(d-1) && delta-k <= d-1 && u+backward[off+delta-k] >= n {
+				return x, y, u, v
+			}
+		}
+		for k := -d; k <= d; k += 2 {
+			var rx int
+			if k == -d || (k != d && backward[off+k-1] < backward[off+k+1]) {
+				rx = backward[off+k+1]
+			} else {
+				rx = backward[off+k-1] + 1
+			}
+			ry := rx - k
+			ru, rv := rx, ry
+			for ru < n && rv < m && as[n-1-ru] == bs[m-1-rv] {
+				ru++
+				rv++
+			}
+			backward[off+k] = ru
+			if !odd && delta-k >= -d && delta-k <= d && ru+forward[off+delta-k] >= n {
+				return n - ru, m - rv, n - rx, m - ry
+			}
+		}
+	}
+	// Unreachable, since the searches overlap after at most max steps.
+	return 0, 0, 0, 0
+}
+
+// Excerpt returns at most max changed lines of the differences, prefixed with
+// `+ ` or `- `. A max of 0 means no limit.
+func Excerpt(lines []Line, max int) (excerpt string) {
+	var n int
+	for _, line := range lines {
+		if line.Kind == Equal {
+			continue
+		}
+		if max > 0 && n == max {
+			excerpt += "...\n"
+			break
+		}
+		excerpt += line.String() + "\n"
+		n++
+	}
+	return excerpt
+}
+
+// Hunks returns the differences grouped into hunks, with at most context
+// unchanged lines before and after each change. Changes which are closer than
+// twice the context are part of the same hunk.
+func Hunks(lines []Line, context int) (hunks []Hunk) {
+	// Keep the changed lines and their context.
+	keep := make([]bool, len(lines))
+	for i, line := range lines {
+		if line.Kind == Equal {
+			continue
+		}
+		for j := i - context; j <= i+context; j++ {
+			if j >= 0 && j < len(lines) {
+				keep[j] = true
+			}
+		}
+	}
+
+	var h *Hunk
+	oldNum, newNum := 1, 1
+	for i, line := range lines {
+		if !keep[i] {
+			h = nil
+		} else {
+			if h == nil {
+				hunks = append(hunks, Hunk{OldStart: oldNum, NewStart: newNum})
+				h = &hunks[len(hunks)-1]
+			}
+			h.Lines = append(h.Lines, line)
+		}
+		if line.Kind != Insert {
+			oldNum++
+			if h != nil {
+				h.OldLines++
+			}
+		}
+		if line.Kind != Delete {
+			newNum++
+			if h != nil {
+				h.NewLines++
+			}
+		}
+	}
+	return hunks
+}
+
+// Unified returns the differences in the unified diff format, with at most
+// context unchanged lines around each change. Unlike excerpts, lines aren't
+// shortened.
+func Unified(lines []Line, context int) (unified string) {
+	var buf strings.Builder
+	for _, h := range Hunks(lines, context) {
+		buf.WriteString(h.Header() + "\n")
+		for _, line := range h.Lines {
+			buf.WriteString(string(line.Kind) + " " + line.Text + "\n")
+		}
+	}
+	return buf.String()
+}
+
+// split splits s into lines. A trailing newline doesn't result in an empty
+// last line.
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,121 @@
+package diff
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// Tests Excerpt
+func TestExcerpt(t *testing.T) {
+	var testTable = []struct {
+		a, b     string
+		max      int
+		expected string
+	}{
+		{"a\nb\nc\n", "a\nb\nc\n", 0, ""},
+		{"a\nb\nc\n", "a\nx\nc\n", 0, "- b\n+ x\n"},
+		{"a\nb\n", "a\nb\nc\nd\n", 0, "+ c\n+ d\n"},
+		{"a\nb\nc\nd\n", "b\nd\n", 0, "- a\n- c\n"},
+		{"", "a\nb\nc\n", 2, "+ a\n+ b\n...\n"},
+		{"a", strings.Repeat("x", 250), 0, "- a\n+ " + strings.Repeat("x", 200) + "...\n"},
+	}
+	for _, test := range testTable {
+		output := Excerpt(Lines(test.a, test.b), test.max)
+		if output != test.expected {
+			t.Errorf("output `%v` != expected `%v`", output, test.expected)
+		}
+	}
+}
+
+// Tests Hunks
+func TestHunks(t *testing.T) {
+	var testTable = []struct {
+		a, b     string
+		context  int
+		expected string
+	}{
+		{"a\nb\nc\n", "a\nb\nc\n", 3, ""},
+		{"a\nb\nc\nd\ne\n", "a\nb\nx\nd\ne\n", 1, "@@ -2,3 +2,3 @@\n  b\n- c\n+ x\n  d\n"},
+		{"a\nb\nc\nd\ne\nf\ng\n", "x\nb\nc\nd\ne\nf\ny\n", 1, "@@ -1,2 +1,2 @@\n- a\n+ x\n  b\n@@ -6,2 +6,2 @@\n  f\n- g\n+ y\n"},
+		{"a\nb\nc\nd\n", "x\nb\nc\ny\n", 1, "@@ -1,4 +1,4 @@\n- a\n+ x\n  b\n  c\n- d\n+ y\n"},
+		{"a\nb\n", "a\nb\nc\n", 0, "@@ -3,0 +3,1 @@\n+ c\n"},
+	}
+	for _, test := range testTable {
+		var output string
+		for _, h := range Hunks(Lines(test.a, test.b), test.context) {
+			output += h.Header() + "\n"
+			for _, line := range h.Lines {
+				output += line.String() + "\n"
+			}
+		}
+		if output != test.expected {
+			t.Errorf("output `%v` != expected `%v`", output, test.expected)
+		}
+	}
+}
+
+// Tests that Unified doesn't shorten lines
+func TestUnified(t *testing.T) {
+	long := strings.Repeat("x", maxLineLen+1)
+	expected := "@@ -1,1 +1,1 @@\n- a\n+ " + long + "\n"
+	if output := Unified(Lines("a\n", long+"\n"), 3); output != expected {
+		t.Errorf("output `%v` != expected `%v`", output, expected)
+	}
+}
+
+// Tests that Lines reproduces both inputs with a minimal number of changes
+func TestLines(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	random := func() string {
+		var s string
+		for i := rnd.Intn(12); i > 0; i-- {
+			s += string(rune('a'+rnd.Intn(3))) + "\n"
+		}
+		return s
+	}
+	for i := 0; i < 2000; i++ {
+		a, b := random(), random()
+		var oldText, newText string
+		var changes int
+		for _, line := range Lines(a, b) {
+			if line.Kind != Insert {
+				oldText += line.Text + "\n"
+			}
+			if line.Kind != Delete {
+				newText += line.Text + "\n"
+			}
+			if line.Kind != Equal {
+				changes++
+			}
+		}
+		if oldText != a || newText != b {
+			t.Fatalf("%q -> %q: output `%q -> %q` != expected inputs", a, b, oldText, newText)
+		}
+		as, bs := split(a), split(b)
+		if expected := len(as) + len(bs) - 2*lcs(as, bs); changes != expected {
+			t.Fatalf("%q -> %q: output `%v` != expected `%v`", a, b, changes, expected)
+		}
+	}
+}
+
+// lcs returns the length of the longest common subsequence of as and bs.
+func lcs(as, bs []string) int {
+	l := make([][]int, len(as)+1)
+	for i := range l {
+		l[i] = make([]int, len(bs)+1)
+	}
+	for i := len(as) - 1; i >= 0; i-- {
+		for j := len(bs) - 1; j >= 0; j-- {
+			switch {
+			case as[i] == bs[j]:
+				l[i][j] = l[i+1][j+1] + 1
+			case l[i+1][j] >= l[i][j+1]:
+				l[i][j] = l[i+1][j]
+			default:
+				l[i][j] = l[i][j+1]
+			}
+		}
+	}
+	return l[0][0]
+}
@@ -0,0 +1,183 @@
+// Package filter pipes selections through external programs, which makes it
+// possible to extend nyfiken with custom normalisers, e.g. PDF-to-text
+// converters or JavaScript beautifiers.
+package filter
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/mewkiz/pkg/errutil"
+)
+
+// Filter types.
+const (
+	// TypeCmd pipes the selection through an external command, e.g.
+	// `cmd:/usr/local/bin/normalize --flag`.
+	TypeCmd = "cmd"
+)
+
+// TypeSep separates the type of a filter from its declaration.
+const TypeSep = ":"
+
+// Time to wait for the output of a killed command to be closed, since children
+// of the command may keep it open.
+const waitDelay = time.Second
+
+// Error messages.
+var (
+	errInvalidType    = "filter: invalid filter type `%s`; correct syntax -> `cmd:/path/to/command [args...]`."
+	errEmptyCommand   = "filter: empty command."
+	errUnclosedQuote  = "filter: unclosed quote in `%s`."
+	errTrailingEscape = "filter: trailing backslash in `%s`."
+	errTimeout        = "filter: timeout after %v: %s"
+	errCommandFailure = "filter: %s: %v: %s"
+)
+
+// Cmd is an external command which reads the selection from standard input
+// and writes the new selection to standard output.
+type Cmd struct {
+	Path string   // Path to the executable.
+	Args []string // Arguments passed to the executable.
+}
+
+// Parse parses a filter declaration (e.g. `cmd:/usr/bin/tr a-z A-Z`). Arguments
+// containing whitespace may be quoted with single or double quotes.
+func Parse(decl string) (cmd *Cmd, err error) {
+	i := strings.Index(decl, TypeSep)
+	if i == -1 {
+		return nil, errutil.NewNoPosf(errInvalidType, decl)
+	}
+	typ := strings.ToLower(strings.TrimSpace(decl[:i]))
+	if typ != TypeCmd {
+		return nil, errutil.NewNoPosf(errInvalidType, typ)
+	}
+
+	words, err := fields(decl[i+len(TypeSep):])
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	if len(words) == 0 {
+		return nil, errutil.NewNoPos(errEmptyCommand)
+	}
+	return &Cmd{Path: words[0], Args: words[1:]}, nil
+}
+
+// Run pipes the selection sel through the command and returns its output. The
+// command is killed if it hasn't finished within the timeout.
+func (cmd *Cmd) Run(sel string, timeout time.Duration) (out string, err error) {
+	buf, err := cmd.Output(sel, timeout, 0)
+	if err != nil {
+		return "", errutil.Err(err)
+	}
+	return string(buf), nil
+}
+
+// Output pipes the selection sel through the command and returns its output.
+// Output larger than max bytes is only read up to one byte more than allowed,
+// after which the command is killed, so that the size check doesn't require
+// reading it into memory; 0 means no limit.
+func (cmd *Cmd) Output(sel string, timeout time.Duration, max int64) (out []byte, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	c := exec.CommandContext(ctx, cmd.Path, cmd.Args...)
+	c.Stdin = strings.NewReader(sel)
+	c.WaitDelay = waitDelay
+	stdout := &limitedWriter{max: max, cancel: cancel}
+	var stderr bytes.Buffer
+	c.Stdout = stdout
+	c.Stderr = &stderr
+
+	err = c.Run()
+	if stdout.full {
+		return stdout.buf.Bytes(), nil
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, errutil.NewNoPosf(errTimeout, timeout, cmd)
+	}
+	if err != nil {
+		return nil, errutil.NewNoPosf(errCommandFailure, cmd, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.buf.Bytes(), nil
+}
+
+// A limitedWriter buffers at most max+1 bytes; 0 means no limit. The command
+// writing to it is cancelled once the limit has been exceeded.
+type limitedWriter struct {
+	buf    bytes.Buffer
+	max    int64
+	cancel func()
+	full   bool
+}
+
+func (w *limitedWriter) Write(p []byte) (n int, err error) {
+	if w.max <= 0 {
+		return w.buf.Write(p)
+	}
+	left := w.max + 1 - int64(w.buf.Len())
+	if int64(len(p)) < left {
+		return w.buf.Write(p)
+	}
+	w.buf.Write(p[:left])
+	w.full = true
+	w.cancel()
+	return int(left), io.ErrShortWrite
+}
+
+// String returns the command line of the command.
+func (cmd *Cmd) String() string {
+	return strings.Join(append([]string{cmd.Path}, cmd.Args...), " ")
+}
+
+// fields splits a command line into words separated by whitespace. Single and
+// double quotes group words containing whitespace, and a backslash escapes the
+// following character outside of single quotes.
+func fields(cmdline string) (words []string, err error) {
+	var word []rune
+	var quote rune
+	inWord := false
+	escaped := false
+	for _, chr := range cmdline {
+		switch {
+		case escaped:
+			word = append(word, chr)
+			escaped = false
+		case chr == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if chr == quote {
+				quote = 0
+			} else {
+				word = append(word, chr)
+			}
+		case chr == '\'' || chr == '"':
+			quote = chr
+			inWord = true
+		case chr == ' ' || chr == '\t' || chr == '\n':
+			if inWord {
+				words = append(words, string(word))
+				word = nil
+				inWord = false
+			}
+		default:
+			word = append(word, chr)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, errutil.NewNoPosf(errUnclosedQuote, cmdline)
+	}
+	if escaped {
+		return nil, errutil.NewNoPosf(errTrailingEscape, cmdline)
+	}
+	if inWord {
+		words = append(words, string(word))
+	}
+	return words, nil
+}
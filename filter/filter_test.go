@@ -0,0 +1,114 @@
+package filter
+
+import (
+	"testing"
+	"time"
+)
+
+// Tests Parse
+func TestParse(t *testing.T) {
+	var testTable = []struct {
+		decl  string
+		path  string
+		args  []string
+		valid bool
+	}{
+		{"cmd:/usr/bin/tr a-z A-Z", "/usr/bin/tr", []string{"a-z", "A-Z"}, true},
+		{"CMD: sed 's/a b/c/'", "sed", []string{"s/a b/c/"}, true},
+		{`cmd:grep -e "two words" \"x`, "grep", []string{"-e", "two words", `"x`}, true},
+		{"cmd:", "", nil, false},
+		{"cmd:sed 's/a", "", nil, false},
+		{`cmd:echo a\`, "", nil, false},
+		{"/usr/bin/tr", "", nil, false},
+		{"url:http://example.org", "", nil, false},
+	}
+
+	for _, test := range testTable {
+		cmd, err := Parse(test.decl)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%s: expected error", test.decl)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.decl, err)
+			continue
+		}
+		if cmd.Path != test.path || len(cmd.Args) != len(test.args) {
+			t.Errorf("output `%v` != expected `%v %v`", cmd, test.path, test.args)
+			continue
+		}
+		for i := range cmd.Args {
+			if cmd.Args[i] != test.args[i] {
+				t.Errorf("output `%v` != expected `%v`", cmd.Args[i], test.args[i])
+			}
+		}
+	}
+}
+
+// Tests Run
+func TestRun(t *testing.T) {
+	var testTable = []struct {
+		decl  string
+		in    string
+		out   string
+		valid bool
+	}{
+		{"cmd:tr a-z A-Z", "love", "LOVE", true},
+		{"cmd:sh -c 'exit 1'", "love", "", false},
+		{"cmd:sleep 5", "love", "", false},
+	}
+
+	for _, test := range testTable {
+		cmd, err := Parse(test.decl)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.decl, err)
+			continue
+		}
+		out, err := cmd.Run(test.in, 500*time.Millisecond)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%s: expected error", test.decl)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.decl, err)
+		}
+		if out != test.out {
+			t.Errorf("output `%v` != expected `%v`", out, test.out)
+		}
+	}
+}
+
+// Tests that Output reads at most one byte more than allowed
+func TestOutput(t *testing.T) {
+	cmd, err := Parse("cmd:yes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := cmd.Output("", 5*time.Second, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 11 {
+		t.Errorf("output `%v` != expected `%v`", len(out), 11)
+	}
+}
+
+// Tests that Run doesn't wait for children of a killed command which keep its
+// output open
+func TestRunWaitDelay(t *testing.T) {
+	cmd, err := Parse("cmd:sh -c 'sleep 10 & sleep 10'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if _, err = cmd.Run("", 100*time.Millisecond); err == nil {
+		t.Errorf("expected error")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("output `%v` != expected less than `%v`", d, 5*time.Second)
+	}
+}
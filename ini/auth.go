@@ -0,0 +1,205 @@
+package ini
+
+import (
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/karlek/nyfiken/settings"
+	"github.com/mewbak/ini"
+	"github.com/mewkiz/pkg/errutil"
+)
+
+// Authentication field names.
+const (
+	fieldAuth       = "auth"
+	fieldAuthUser   = "authuser"
+	fieldAuthEnv    = "authenv"
+	fieldAuthSecret = "authsecret"
+)
+
+// Error messages.
+var (
+	errInvalidAuth     = "ini: invalid auth: `%s`; correct syntax -> `" + settings.AuthBasic + "` or `" + settings.AuthBearer + "`."
+	errAuthNoSecret    = "ini: no credentials for `%s`; use `" + fieldAuthEnv + "`, `" + fieldAuthSecret + "` or a netrc entry."
+	errAuthEnvNotSet   = "ini: environment variable `%s` is not set."
+	errSecretNotExist  = "ini: secret `%s` doesn't exist in %s."
+	errInsecureSecrets = "ini: %s may be read by other users; restrict its permissions with `chmod 600 %s`."
+	errMultipleAuth    = "ini: use either `" + fieldAuth + "` or an Authorization header, not both."
+	errAuthFieldNoAuth = "ini: `%s` requires `" + fieldAuth + "`."
+)
+
+// parseAuth parses the HTTP authentication settings of a page. The password or
+// token is read from an environment variable (`authenv`), from the secrets file
+// (`authsecret`) or from the netrc entry of host, in that order, so that
+// pages.ini doesn't have to contain any secrets. It returns nil if the page
+// doesn't authenticate.
+func parseAuth(section ini.Section, header map[string]string, host string) (a *settings.Auth, err error) {
+	typ := strings.ToLower(section.S(fieldAuth, ""))
+	if typ == "" {
+		for _, fieldName := range []string{fieldAuthUser, fieldAuthEnv, fieldAuthSecret} {
+			if _, found := section[fieldName]; found {
+				return nil, errutil.NewNoPosf(errAuthFieldNoAuth, fieldName)
+			}
+		}
+		return nil, nil
+	}
+	if typ != settings.AuthBasic && typ != settings.AuthBearer {
+		return nil, errutil.NewNoPosf(errInvalidAuth, typ)
+	}
+	if hasHeader(header, "Authorization") {
+		return nil, errutil.NewNoPosf(errMultipleAuth)
+	}
+
+	a = &settings.Auth{Type: typ, User: section.S(fieldAuthUser, "")}
+	env := section.S(fieldAuthEnv, "")
+	secret := section.S(fieldAuthSecret, "")
+	switch {
+	case env != "":
+		var found bool
+		a.Password, found = os.LookupEnv(env)
+		if !found {
+			return nil, errutil.NewNoPosf(errAuthEnvNotSet, env)
+		}
+	case secret != "":
+		a.Password, err = readSecret(settings.SecretsPath, secret)
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+	default:
+		login, password, err := netrc(settings.NetrcPath, host)
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+		if password == "" {
+			return nil, errutil.NewNoPosf(errAuthNoSecret, host)
+		}
+		if a.User == "" {
+			a.User = login
+		}
+		a.Password = password
+	}
+
+	return a, nil
+}
+
+// readSecret returns the secret by the provided name from the secrets file,
+// which contains `name = secret` pairs. The file must only be accessible by its
+// owner.
+func readSecret(path, name string) (secret string, err error) {
+	err = checkPerms(path)
+	if err != nil {
+		return "", errutil.Err(err)
+	}
+
+	file := ini.New()
+	err = file.Load(path)
+	if err != nil {
+		return "", errutil.Err(err)
+	}
+	secrets := file.Sections[""]
+	if _, found := secrets[name]; !found {
+		return "", errutil.NewNoPosf(errSecretNotExist, name, path)
+	}
+	return secrets.S(name, ""), nil
+}
+
+// netrc returns the login and password of host from the netrc file at path. The
+// default entry is used if no machine entry matches host. Empty strings are
+// returned if the file doesn't exist or contains no matching entry.
+func netrc(path, host string) (login, password string, err error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", "", nil
+		}
+		return "", "", errutil.Err(err)
+	}
+	// Like ftp and curl, refuse to use passwords readable by other users.
+	err = checkPerms(path)
+	if err != nil {
+		return "", "", errutil.Err(err)
+	}
+
+	// entry is the machine or default entry currently being parsed.
+	type entry struct {
+		login, password string
+	}
+	var machine, def *entry
+	var cur *entry
+	lines := strings.Split(string(buf), "\n")
+	for i := 0; i < len(lines); i++ {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), "#") {
+			continue
+		}
+		tokens := strings.Fields(lines[i])
+		for j := 0; j < len(tokens); j++ {
+			switch tokens[j] {
+			case "machine":
+				if machine != nil {
+					return machine.login, machine.password, nil
+				}
+				cur = nil
+				if j+1 < len(tokens) {
+					j++
+					if strings.EqualFold(tokens[j], host) {
+						machine = new(entry)
+						cur = machine
+					}
+				}
+			case "default":
+				if machine != nil {
+					return machine.login, machine.password, nil
+				}
+				def = new(entry)
+				cur = def
+			case "login", "password", "account":
+				if j+1 >= len(tokens) {
+					break
+				}
+				j++
+				if cur == nil {
+					continue
+				}
+				switch tokens[j-1] {
+				case "login":
+					cur.login = tokens[j]
+				case "password":
+					cur.password = tokens[j]
+				}
+			case "macdef":
+				// Macro definitions end with an empty line.
+				for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+					i++
+				}
+				j = len(tokens)
+			}
+		}
+	}
+
+	switch {
+	case machine != nil:
+		return machine.login, machine.password, nil
+	case def != nil:
+		return def.login, def.password, nil
+	}
+	return "", "", nil
+}
+
+// checkPerms returns an error if the file at path may be accessed by other
+// users than its owner.
+func checkPerms(path string) (err error) {
+	// Windows doesn't use Unix permission bits.
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	if fi.Mode().Perm()&0077 != 0 {
+		return errutil.NewNoPosf(errInsecureSecrets, path, path)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package ini
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// Tests netrc
+func TestNetrc(t *testing.T) {
+	data := `# Comment
+machine example.org login alice password secret
+machine other.example.org
+	login bob
+	password hunter2
+macdef init
+	cd /pub
+	machine example.net
+
+default login anonymous password guest
+`
+	f, err := ioutil.TempFile("", "netrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if err = os.Chmod(f.Name(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	golden := []struct {
+		host     string
+		login    string
+		password string
+	}{
+		{host: "example.org", login: "alice", password: "secret"},
+		{host: "other.example.org", login: "bob", password: "hunter2"},
+		{host: "example.net", login: "anonymous", password: "guest"},
+	}
+	for _, g := range golden {
+		login, password, err := netrc(f.Name(), g.host)
+		if err != nil {
+			t.Errorf("%s: %s", g.host, err)
+			continue
+		}
+		if login != g.login || password != g.password {
+			t.Errorf("output `%v:%v` != expected `%v:%v`", login, password, g.login, g.password)
+		}
+	}
+
+	// Passwords readable by other users are refused.
+	if err = os.Chmod(f.Name(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = netrc(f.Name(), "example.org"); err == nil {
+		t.Errorf("expected error for world-readable netrc")
+	}
+
+	// A missing netrc file has no entries.
+	login, password, err := netrc(f.Name()+".missing", "example.org")
+	if err != nil || login != "" || password != "" {
+		t.Errorf("output `%v:%v` (%v) != expected empty", login, password, err)
+	}
+}
+
+// Tests readSecret
+func TestReadSecret(t *testing.T) {
+	f, err := ioutil.TempFile("", "secrets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.WriteString("example = s3cr3t\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if err = os.Chmod(f.Name(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	secret, err := readSecret(f.Name(), "example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret != "s3cr3t" {
+		t.Errorf("output `%v` != expected `%v`", secret, "s3cr3t")
+	}
+	if _, err = readSecret(f.Name(), "missing"); err == nil {
+		t.Errorf("expected error for missing secret")
+	}
+
+	if err = os.Chmod(f.Name(), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = readSecret(f.Name(), "example"); err == nil {
+		t.Errorf("expected error for group-readable secrets file")
+	}
+}
@@ -2,14 +2,21 @@
 package ini
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/karlek/nyfiken/filter"
+	mailpkg "github.com/karlek/nyfiken/mail"
+	"github.com/karlek/nyfiken/notify"
 	"github.com/karlek/nyfiken/page"
 	"github.com/karlek/nyfiken/settings"
+	"github.com/karlek/nyfiken/strip"
 	"github.com/mewbak/ini"
 	"github.com/mewkiz/pkg/errutil"
 )
@@ -22,35 +29,76 @@ const (
 
 // INI field names.
 const (
+	fieldBody           = "body"
 	fieldBrowser        = "browser"
+	fieldCertExpiry     = "certexpiry"
+	fieldCookieJar      = "cookiejar"
+	fieldDiffContext    = "diffcontext"
+	fieldDigest         = "digest"
 	fieldFilePerms      = "fileperms"
+	fieldFilter         = "filter"
+	fieldFilterTimeout  = "filtertimeout"
+	fieldForm           = "form"
 	fieldHeader         = "header"
+	fieldHelo           = "helo"
+	fieldHTMLTemplate   = "htmltemplate"
 	fieldInterval       = "interval"
+	fieldJSON           = "json"
+	fieldMaxBodySize    = "maxbodysize"
+	fieldMethod         = "method"
 	fieldNegexp         = "negexp"
 	fieldPortNum        = "portnum"
 	fieldRecvMail       = "recvmail"
 	fieldRegexp         = "regexp"
+	fieldSecurity       = "security"
 	fieldSelection      = "sel"
 	fieldSendAuthServer = "sendauthserver"
 	fieldSendMail       = "sendmail"
+	fieldSendmailPath   = "sendmailpath"
 	fieldSendOutServer  = "sendoutserver"
 	fieldSendPass       = "sendpass"
 	fieldSleepStart     = "sleepstart"
 	fieldStrip          = "strip"
+	fieldSubject        = "subject"
+	fieldTextTemplate   = "texttemplate"
 	fieldThreshold      = "threshold"
+	fieldTimeout        = "timeout"
+	fieldWatch          = "watch"
 )
 
 var (
 	// Valid fields in different sections
 	siteFields = map[string]bool{
-		fieldInterval:  true,
-		fieldStrip:     true,
-		fieldRecvMail:  true,
-		fieldSelection: true,
-		fieldRegexp:    true,
-		fieldNegexp:    true,
-		fieldThreshold: true,
-		fieldHeader:    true,
+		fieldInterval:      true,
+		fieldStrip:         true,
+		fieldRecvMail:      true,
+		fieldSelection:     true,
+		fieldRegexp:        true,
+		fieldNegexp:        true,
+		fieldThreshold:     true,
+		fieldHeader:        true,
+		fieldFilter:        true,
+		fieldFilterTimeout: true,
+		fieldMaxBodySize:   true,
+		fieldMethod:        true,
+		fieldBody:          true,
+		fieldForm:          true,
+		fieldJSON:          true,
+		fieldCookieJar:     true,
+		fieldAuth:          true,
+		fieldAuthUser:      true,
+		fieldAuthEnv:       true,
+		fieldAuthSecret:    true,
+		fieldWatch:         true,
+		fieldCertExpiry:    true,
+		fieldNotify:        true,
+		fieldCC:            true,
+
+		fieldLogin:                   true,
+		fieldLogin + fieldLoginURL:   true,
+		fieldLogin + fieldLoginForm:  true,
+		fieldLogin + fieldLoginField: true,
+		fieldLogin + fieldLoginMatch: true,
 	}
 	mailFields = map[string]bool{
 		fieldRecvMail:       true,
@@ -58,12 +106,23 @@ var (
 		fieldSendPass:       true,
 		fieldSendAuthServer: true,
 		fieldSendOutServer:  true,
+		fieldSubject:        true,
+		fieldTextTemplate:   true,
+		fieldHTMLTemplate:   true,
+		fieldDiffContext:    true,
+		fieldDigest:         true,
+		fieldSecurity:       true,
+		fieldAuth:           true,
+		fieldHelo:           true,
+		fieldTimeout:        true,
+		fieldSendmailPath:   true,
 	}
 	settingsFields = map[string]bool{
-		fieldInterval:  true,
-		fieldBrowser:   true,
-		fieldPortNum:   true,
-		fieldFilePerms: true,
+		fieldInterval:    true,
+		fieldBrowser:     true,
+		fieldPortNum:     true,
+		fieldFilePerms:   true,
+		fieldMaxBodySize: true,
 	}
 )
 
@@ -74,22 +133,26 @@ var (
 	errNoSectionMail          = "ini: no [" + sectionMail + "] section found in config.ini."
 	errInvalidMailAddress     = "ini: invalid mail: `%s`; correct syntax -> `name@domain.tld`."
 	errInvalidHeader          = "ini: invalid header: `%s`; correct syntax -> `HeaderName: Value`."
-	errInvalidStripFunction   = "ini: invalid strip function: `%s`."
+	errInvalidStripFunction   = "ini: invalid strip function: `%s`; %v"
+	errInvalidFilter          = "ini: invalid filter: `%s`; %v"
+	errInvalidWatch           = "ini: invalid watch: `%s`; %v"
+	errInvalidCommand         = "ini: invalid command: `%s`; %v"
+	errInvalidFile            = "ini: invalid file: `%s`; correct syntax -> `file:///path/to/file`."
+	errInvalidCertExpiry      = "ini: invalid certificate expiry: `%s`; correct syntax -> number of days, e.g. `14`."
 	errInvalidRandInterval    = "ini: invalid random interval: %s; correct syntax -> `duration duration`."
 	errMailAddressNotFound    = "ini: global receiving mail required."
 	errMailAuthServerNotFound = "ini: sending mail authorization server required."
 	errMailOutServerNotFound  = "ini: sending mail outgoing server required."
 	errInvalidListDeclaration = "ini: use `<` instead of `=` for list values."
-)
-
-// Whitelist of allowed strip functions.
-var (
-	stripFunctions = map[string]bool{
-		"html":    true,
-		"attrs":   true,
-		"numbers": true,
-		"scripts": true,
-	}
+	errInvalidTemplate        = "ini: invalid mail template; %v"
+	errInvalidSecurity        = "ini: invalid security: `%s`; valid values: starttls, tls and none."
+	errInvalidMailAuth        = "ini: invalid authentication mechanism: `%s`; valid values: plain, login, cram-md5 and none."
+	errInvalidDiffContext     = "ini: invalid diff context: `%s`; correct syntax -> number of lines, e.g. `3`."
+	errInvalidSize            = "ini: invalid size: `%s`; correct syntax -> `512`, `64K`, `10M` or `1G`."
+	errInvalidMethod          = "ini: invalid HTTP method: `%s`."
+	errInvalidForm            = "ini: invalid form value: `%s`; correct syntax -> `key=value`."
+	errInvalidJSON            = "ini: invalid JSON body: %v."
+	errMultipleBodies         = "ini: only one of `" + fieldBody + "`, `" + fieldForm + "` and `" + fieldJSON + "` may be used."
 )
 
 // ReadIni is a convenience function wrapping ReadSettings and ReadPages.
@@ -120,6 +183,13 @@ func ReadSettings(configPath string) (err error) {
 
 	config, settingExist := file.Sections[sectionSettings]
 	mail, mailExist := file.Sections[sectionMail]
+
+	// Parse recipient groups, which may be used by recvmail.
+	settings.Global.Groups, err = parseGroups(file.Sections)
+	if err != nil {
+		return errutil.Err(err)
+	}
+
 	if settingExist {
 		err = parseSettings(config)
 		if err != nil {
@@ -133,6 +203,39 @@ func ReadSettings(configPath string) (err error) {
 		}
 	}
 
+	// Parse named login recipes.
+	settings.Global.Logins, err = parseLogins(file.Sections)
+	if err != nil {
+		return errutil.Err(err)
+	}
+
+	// Parse named notifiers.
+	settings.Global.Notifiers, err = parseNotifiers(file.Sections)
+	if err != nil {
+		return errutil.Err(err)
+	}
+
+	warnInsecure(configPath, file.Sections)
+
+	return nil
+}
+
+// ReadClientSettings reads the [settings] section of the settings file, which is
+// all that nyfikenc needs, and updates settings.Global. Unlike ReadSettings,
+// secret references aren't resolved, since they may only be resolvable in the
+// environment of nyfikend.
+func ReadClientSettings(configPath string) (err error) {
+	file := ini.New()
+	err = file.Load(configPath)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	if config, found := file.Sections[sectionSettings]; found {
+		err = parseSettings(config)
+		if err != nil {
+			return errutil.Err(err)
+		}
+	}
 	return nil
 }
 
@@ -162,6 +265,12 @@ func parseSettings(config ini.Section) (err error) {
 	// Set browser path.
 	settings.Global.Browser = config.S(fieldBrowser, "")
 
+	// Set maximum size of response bodies.
+	settings.Global.MaxBodySize, err = parseSize(config.S(fieldMaxBodySize, ""), settings.DefaultMaxBodySize)
+	if err != nil {
+		return errutil.Err(err)
+	}
+
 	return nil
 }
 
@@ -182,31 +291,243 @@ func parseMail(mail ini.Section) (err error) {
 	}
 
 	// Set global sender mail password.
-	settings.Global.SenderMail.Password = mail.S(fieldSendPass, "")
+	settings.Global.SenderMail.Password, err = resolveSecret(mail.S(fieldSendPass, ""))
+	if err != nil {
+		return errutil.Err(err)
+	}
 
-	// Set global sender authorization server.
-	settings.Global.SenderMail.AuthServer = mail.S(fieldSendAuthServer, "")
-	if settings.Global.SenderMail.AuthServer == "" {
-		return errutil.NewNoPosf(errMailAuthServerNotFound)
+	// Set how mails are delivered.
+	err = parseTransport(mail, &settings.Global.SenderMail)
+	if err != nil {
+		return errutil.Err(err)
 	}
 
+	// Set global receive mail.
+	settings.Global.RecvMail, err = parseRecipients(mail, fieldRecvMail, nil)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	if len(settings.Global.RecvMail) == 0 {
+		return errutil.NewNoPosf(errMailAddressNotFound)
+	}
+
+	// Set templates of notification mails.
+	tmpl := &settings.Global.MailTemplate
+	tmpl.Subject = mail.S(fieldSubject, "")
+	tmpl.Text, err = readTemplate(mail.S(fieldTextTemplate, ""))
+	if err != nil {
+		return errutil.Err(err)
+	}
+	tmpl.HTML, err = readTemplate(mail.S(fieldHTMLTemplate, ""))
+	if err != nil {
+		return errutil.Err(err)
+	}
+	if _, err = mailpkg.ParseTemplates(tmpl.Subject, tmpl.Text, tmpl.HTML); err != nil {
+		return errutil.NewNoPosf(errInvalidTemplate, err)
+	}
+
+	// Set number of context lines of diffs in notification mails.
+	diffContextStr := mail.S(fieldDiffContext, strconv.Itoa(settings.DefaultDiffContext))
+	settings.Global.DiffContext, err = strconv.Atoi(diffContextStr)
+	if err != nil || settings.Global.DiffContext < 0 {
+		return errutil.NewNoPosf(errInvalidDiffContext, diffContextStr)
+	}
+
+	// Set schedule of mail digests.
+	settings.Global.Digest = mail.S(fieldDigest, "")
+	if _, err = notify.ParseSchedule(settings.Global.Digest); err != nil {
+		return errutil.Err(err)
+	}
+
+	return nil
+}
+
+// parseTransport parses how mails are delivered; either by a sendmail binary,
+// or by the outgoing server with the security, authentication mechanism, HELO
+// name and timeout of the [mail] section.
+func parseTransport(mail ini.Section, sender *settings.Sender) (err error) {
+	// Set path of the sendmail binary, which replaces the outgoing server.
+	sender.Sendmail = mail.S(fieldSendmailPath, "")
+
 	// Set global sender mail outgoing server.
-	settings.Global.SenderMail.OutServer = mail.S(fieldSendOutServer, "")
-	if settings.Global.SenderMail.OutServer == "" {
+	sender.OutServer = mail.S(fieldSendOutServer, "")
+	if sender.OutServer == "" && sender.Sendmail == "" {
 		return errutil.NewNoPosf(errMailOutServerNotFound)
 	}
 
-	// Set global receive mail.
-	settings.Global.RecvMail = mail.S(fieldRecvMail, "")
-	if settings.Global.RecvMail == "" {
-		return errutil.NewNoPosf(errMailAddressNotFound)
-	} else if !strings.Contains(settings.Global.RecvMail, "@") {
-		return errutil.NewNoPosf(errInvalidMailAddress, settings.Global.RecvMail)
+	// Set authentication mechanism.
+	sender.Auth = strings.ToLower(mail.S(fieldAuth, settings.MailAuthPlain))
+	switch sender.Auth {
+	case settings.MailAuthPlain, settings.MailAuthLogin, settings.MailAuthCRAMMD5, settings.MailAuthNone:
+	default:
+		return errutil.NewNoPosf(errInvalidMailAuth, sender.Auth)
+	}
+
+	// Set global sender authorization server.
+	sender.AuthServer = mail.S(fieldSendAuthServer, "")
+	if sender.AuthServer == "" && sender.Sendmail == "" && sender.Auth != settings.MailAuthNone {
+		return errutil.NewNoPosf(errMailAuthServerNotFound)
+	}
+
+	// Set security of the connection. Port 465 is used for implicit TLS.
+	security := settings.SecurityStartTLS
+	if strings.HasSuffix(sender.OutServer, ":465") {
+		security = settings.SecurityTLS
+	}
+	sender.Security = strings.ToLower(mail.S(fieldSecurity, security))
+	switch sender.Security {
+	case settings.SecurityStartTLS, settings.SecurityTLS, settings.SecurityNone:
+	default:
+		return errutil.NewNoPosf(errInvalidSecurity, sender.Security)
+	}
+
+	// Set HELO name.
+	sender.Helo = mail.S(fieldHelo, "")
+
+	// Set connection timeout.
+	sender.Timeout, err = time.ParseDuration(mail.S(fieldTimeout, settings.DefaultMailTimeout.String()))
+	if err != nil {
+		return errutil.Err(err)
 	}
 
 	return nil
 }
 
+// readTemplate returns the contents of a template file, or an empty string if
+// no path is given.
+func readTemplate(path string) (text string, err error) {
+	if path == "" {
+		return "", nil
+	}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errutil.Err(err)
+	}
+	return string(buf), nil
+}
+
+// parseRequest parses the HTTP method and request body of a page. The request
+// body is either raw, an URL encoded form or JSON; the Content-Type header is
+// set accordingly unless specified by the user. The method defaults to POST if
+// a request body is present and GET otherwise.
+func parseRequest(section ini.Section, pageSettings *settings.Page) (err error) {
+	body := section.S(fieldBody, "")
+	jsonBody := section.S(fieldJSON, "")
+	form := section.List(fieldForm)
+	if form == nil {
+		if _, found := section[fieldForm]; found {
+			return errutil.NewNoPosf(errInvalidListDeclaration)
+		}
+	}
+
+	var contentType string
+	switch {
+	case body != "" && (jsonBody != "" || form != nil), jsonBody != "" && form != nil:
+		return errutil.NewNoPosf(errMultipleBodies)
+	case body != "":
+		pageSettings.Body = body
+	case jsonBody != "":
+		var v interface{}
+		if err := json.Unmarshal([]byte(jsonBody), &v); err != nil {
+			return errutil.NewNoPosf(errInvalidJSON, err)
+		}
+		pageSettings.Body = jsonBody
+		contentType = "application/json"
+	case form != nil:
+		values := url.Values{}
+		for _, keyVal := range form {
+			if !strings.Contains(keyVal, "=") {
+				return errutil.NewNoPosf(errInvalidForm, keyVal)
+			}
+			kv := strings.SplitN(keyVal, "=", 2)
+			values.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+		}
+		pageSettings.Body = values.Encode()
+		contentType = "application/x-www-form-urlencoded"
+	}
+	if contentType != "" && !hasHeader(pageSettings.Header, "Content-Type") {
+		pageSettings.Header["Content-Type"] = contentType
+	}
+
+	defaultMethod := "GET"
+	if pageSettings.Body != "" {
+		defaultMethod = "POST"
+	}
+	pageSettings.Method = strings.ToUpper(section.S(fieldMethod, defaultMethod))
+	if pageSettings.Method == "" || strings.ContainsAny(pageSettings.Method, " \t()<>@,;:\\\"/[]?={}") {
+		return errutil.NewNoPosf(errInvalidMethod, pageSettings.Method)
+	}
+
+	return nil
+}
+
+// parsePageName parses the name of a page section, which is either the URL of a
+// site, a local file (`file:///path/to/file`) or a command whose output is
+// checked (`exec:command [args...]`).
+func parsePageName(name string) (u *url.URL, err error) {
+	// Commands may contain characters which aren't valid in URLs, so they are
+	// kept verbatim.
+	if strings.HasPrefix(name, page.SchemeExec+":") {
+		u = &url.URL{
+			Scheme: page.SchemeExec,
+			Opaque: strings.TrimSpace(strings.TrimPrefix(name, page.SchemeExec+":")),
+		}
+		if _, err = (&page.Page{ReqUrl: u}).Command(); err != nil {
+			return nil, errutil.NewNoPosf(errInvalidCommand, name, err)
+		}
+		return u, nil
+	}
+
+	u, err = url.Parse(name)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	if u.Scheme == page.SchemeFile && (u.Path == "" || (u.Host != "" && u.Host != "localhost")) {
+		return nil, errutil.NewNoPosf(errInvalidFile, name)
+	}
+	return u, nil
+}
+
+// hasHeader reports whether the header key is present, regardless of case.
+func hasHeader(header map[string]string, key string) bool {
+	for k := range header {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSize parses a size in bytes with an optional binary unit suffix (K, M or
+// G), e.g. `64K`. The default value def is returned if s is empty.
+func parseSize(sizeStr string, def int64) (size int64, err error) {
+	s := strings.ToUpper(strings.TrimSpace(sizeStr))
+	if s == "" {
+		return def, nil
+	}
+
+	// Units are case insensitive and may be written as `M`, `MB` or `MiB`.
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	var unit int64 = 1
+	switch {
+	case strings.HasSuffix(s, "K"):
+		unit = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		unit = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		unit = 1 << 30
+	}
+	if unit != 1 {
+		s = s[:len(s)-1]
+	}
+
+	size, err = strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || size < 0 {
+		return 0, errutil.NewNoPosf(errInvalidSize, sizeStr)
+	}
+	return size * unit, nil
+}
+
 // ReadPages reads pages file and returns a slice of pages.
 func ReadPages(pagesPath string) (pages []*page.Page, err error) {
 
@@ -217,6 +538,8 @@ func ReadPages(pagesPath string) (pages []*page.Page, err error) {
 		return nil, errutil.Err(err)
 	}
 
+	warnInsecure(pagesPath, file.Sections)
+
 	// Loop through the INI sections ([section]) and parse page settings.
 	for name, section := range file.Sections {
 		// Skip global scope INI values since they are empty.
@@ -238,7 +561,7 @@ func ReadPages(pagesPath string) (pages []*page.Page, err error) {
 		var pageSettings settings.Page
 
 		// Make INI section ([http://example.org]) into url.URL.
-		p.ReqUrl, err = url.Parse(name)
+		p.ReqUrl, err = parsePageName(name)
 		if err != nil {
 			return nil, errutil.Err(err)
 		}
@@ -264,10 +587,20 @@ func ReadPages(pagesPath string) (pages []*page.Page, err error) {
 			return nil, errutil.Err(err)
 		}
 
-		// Set individual mail address.
-		pageSettings.RecvMail = section.S(fieldRecvMail, settings.Global.RecvMail)
-		if pageSettings.RecvMail != "" && !strings.Contains(pageSettings.RecvMail, "@") {
-			return nil, errutil.NewNoPosf(errInvalidMailAddress, pageSettings.RecvMail)
+		// Set individual mail addresses.
+		pageSettings.RecvMail, err = parseRecipients(section, fieldRecvMail, settings.Global.RecvMail)
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+		pageSettings.CC, err = parseRecipients(section, fieldCC, nil)
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+
+		// Set notifiers to notify about updates.
+		pageSettings.Notify, err = pageNotifiers(section)
+		if err != nil {
+			return nil, errutil.Err(err)
 		}
 
 		// Set individual header.
@@ -276,7 +609,11 @@ func ReadPages(pagesPath string) (pages []*page.Page, err error) {
 		for _, header := range headers {
 			if strings.Contains(header, ":") {
 				keyVal := strings.SplitN(header, ":", 2)
-				m[strings.TrimSpace(keyVal[0])] = strings.TrimSpace(keyVal[1])
+				val, err := resolveNamedSecret(keyVal[0], strings.TrimSpace(keyVal[1]))
+				if err != nil {
+					return nil, errutil.Err(err)
+				}
+				m[strings.TrimSpace(keyVal[0])] = val
 			} else {
 				return nil, errutil.NewNoPosf(errInvalidHeader, header)
 			}
@@ -291,10 +628,81 @@ func ReadPages(pagesPath string) (pages []*page.Page, err error) {
 			}
 		}
 		for _, stripFunc := range pageSettings.StripFuncs {
-			if _, found := stripFunctions[stripFunc]; !found {
-				return nil, errutil.NewNoPosf(errInvalidStripFunction, stripFunc)
+			if _, err := strip.Parse(stripFunc); err != nil {
+				return nil, errutil.NewNoPosf(errInvalidStripFunction, stripFunc, err)
+			}
+		}
+
+		// Set external filter commands to pipe the selection through.
+		pageSettings.Filters = section.List(fieldFilter)
+		if pageSettings.Filters == nil {
+			if _, found := section[fieldFilter]; found {
+				return nil, errutil.NewNoPosf(errInvalidListDeclaration)
+			}
+		}
+		for _, decl := range pageSettings.Filters {
+			if _, err := filter.Parse(decl); err != nil {
+				return nil, errutil.NewNoPosf(errInvalidFilter, decl, err)
 			}
 		}
+
+		// Set parts of the response to watch for changes.
+		pageSettings.Watch = section.List(fieldWatch)
+		if pageSettings.Watch == nil {
+			if _, found := section[fieldWatch]; found {
+				return nil, errutil.NewNoPosf(errInvalidListDeclaration)
+			}
+		}
+		for _, decl := range pageSettings.Watch {
+			if _, _, err := page.ParseWatch(decl); err != nil {
+				return nil, errutil.NewNoPosf(errInvalidWatch, decl, err)
+			}
+		}
+
+		// Set days before a watched certificate expires to notify the user.
+		certExpiryStr := section.S(fieldCertExpiry, strconv.Itoa(settings.DefaultCertExpiry))
+		days, err := strconv.Atoi(certExpiryStr)
+		if err != nil || days < 0 {
+			return nil, errutil.NewNoPosf(errInvalidCertExpiry, certExpiryStr)
+		}
+		pageSettings.CertExpiry = time.Duration(days) * 24 * time.Hour
+
+		// Set filter timeout.
+		filterTimeoutStr := section.S(fieldFilterTimeout, settings.DefaultFilterTimeout.String())
+		// Parse string to duration.
+		pageSettings.FilterTimeout, err = time.ParseDuration(filterTimeoutStr)
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+
+		// Set request body and method.
+		err = parseRequest(section, &pageSettings)
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+
+		// Set login recipe. Inline recipes store their cookies in a jar named
+		// after the page.
+		pageSettings.Login, err = pageLogin(section, name)
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+
+		// Set HTTP authentication credentials.
+		pageSettings.Auth, err = parseAuth(section, pageSettings.Header, p.ReqUrl.Hostname())
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+
+		// Set persistent cookie jar.
+		pageSettings.CookieJar = section.S(fieldCookieJar, "")
+
+		// Set maximum size of the response body.
+		pageSettings.MaxBodySize, err = parseSize(section.S(fieldMaxBodySize, ""), settings.Global.MaxBodySize)
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+
 		p.Settings = pageSettings
 
 		pages = append(pages, &p)
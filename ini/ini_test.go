@@ -2,6 +2,7 @@ package ini
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/url"
 	"os"
 	"testing"
@@ -16,21 +17,27 @@ func TestReadSettings(t *testing.T) {
 	// Expected output of ReadSettings.
 	expected := settings.Prog{
 		Interval:  10 * time.Minute,
-		RecvMail:  "global@example.com",
+		RecvMail:  []string{"global@example.com"},
 		FilePerms: os.FileMode(0777),
 		PortNum:   ":4113",
 		Browser:   "/usr/bin/browser",
 
-		SenderMail: struct {
-			Address    string
-			Password   string
-			AuthServer string
-			OutServer  string
-		}{
+		MaxBodySize: 1 << 20,
+
+		SenderMail: settings.Sender{
 			Address:    "sender@example.com",
 			Password:   "123456",
 			AuthServer: "auth.server.com",
 			OutServer:  "out.server.com:587",
+			Security:   settings.SecurityStartTLS,
+			Auth:       settings.MailAuthPlain,
+			Timeout:    settings.DefaultMailTimeout,
+		},
+
+		DiffContext: settings.DefaultDiffContext,
+
+		Groups: map[string][]string{
+			"team": {`"Alice" <alice@example.org>`, "bob@example.org"},
 		},
 	}
 
@@ -47,6 +54,29 @@ func TestReadSettings(t *testing.T) {
 	}
 }
 
+// Tests that ReadClientSettings doesn't resolve secrets
+func TestReadClientSettings(t *testing.T) {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("[settings]\nbrowser = /usr/bin/browser\n\n[mail]\nsendpass = env:NYFIKEN_TEST_UNSET\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	settings.Global.Browser = ""
+	err = ReadClientSettings(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "/usr/bin/browser"; settings.Global.Browser != expected {
+		t.Errorf("output `%v` != expected `%v`", settings.Global.Browser, expected)
+	}
+}
+
 // Tests ReadPages
 func TestReadPages(t *testing.T) {
 	reqUrl, err := url.Parse("http://example.org")
@@ -58,17 +88,26 @@ func TestReadPages(t *testing.T) {
 		t.Errorf("url.Parse: %s", err)
 	}
 
+	postReqUrl, err := url.Parse("http://post.example.org/search")
+	if err != nil {
+		t.Errorf("url.Parse: %s", err)
+	}
+
+	execReqUrl := &url.URL{Scheme: page.SchemeExec, Opaque: "echo nyfiken"}
+
 	expected := []*page.Page{
 		{
 			ReqUrl: reqUrl,
 			Settings: settings.Page{
 				Interval:  3 * time.Minute,
 				Threshold: 0.05,
-				RecvMail:  "mail@example.org",
+				RecvMail:  []string{"mail@example.org"},
+				CC:        []string{`"Alice" <alice@example.org>`, "bob@example.org"},
 				Selection: "html body",
 				StripFuncs: []string{
 					"html",
 					"numbers",
+					"remove:script,.ad",
 				},
 				Regexp: "(love)",
 				Negexp: "(hate)",
@@ -76,6 +115,7 @@ func TestReadPages(t *testing.T) {
 					"Cookie":     "IloveCookies=1;",
 					"User-Agent": "I come in peace",
 				},
+				Method: "GET",
 			},
 		},
 		{
@@ -84,6 +124,28 @@ func TestReadPages(t *testing.T) {
 				Interval:  settings.Global.Interval,
 				RecvMail:  settings.Global.RecvMail,
 				Selection: "#main-content",
+				Method:    "GET",
+			},
+		},
+		{
+			ReqUrl: postReqUrl,
+			Settings: settings.Page{
+				Interval: settings.Global.Interval,
+				RecvMail: settings.Global.RecvMail,
+				Header: map[string]string{
+					"Content-Type": "application/x-www-form-urlencoded",
+				},
+				Method: "POST",
+				Body:   "lang=sv&q=nyfiken",
+			},
+		},
+		{
+			ReqUrl: execReqUrl,
+			Settings: settings.Page{
+				Interval: settings.Global.Interval,
+				RecvMail: settings.Global.RecvMail,
+				Regexp:   "nyfiken",
+				Method:   "GET",
 			},
 		},
 	}
@@ -114,8 +176,10 @@ func TestReadPages(t *testing.T) {
 				t.Errorf("Negexp output %v != %v", p.Settings.Negexp, expectedP.Settings.Negexp)
 			case p.Settings.Regexp != expectedP.Settings.Regexp:
 				t.Errorf("Regexp output %v != %v", p.Settings.Regexp, expectedP.Settings.Regexp)
-			case p.Settings.RecvMail != expectedP.Settings.RecvMail:
+			case fmt.Sprint(p.Settings.RecvMail) != fmt.Sprint(expectedP.Settings.RecvMail):
 				t.Errorf("RecvMail output %v != %v", p.Settings.RecvMail, expectedP.Settings.RecvMail)
+			case fmt.Sprint(p.Settings.CC) != fmt.Sprint(expectedP.Settings.CC):
+				t.Errorf("CC output %v != %v", p.Settings.CC, expectedP.Settings.CC)
 			case p.Settings.Selection != expectedP.Settings.Selection:
 				t.Errorf("Selection output %v != %v", p.Settings.Selection, expectedP.Settings.Selection)
 			case p.Settings.Threshold != expectedP.Settings.Threshold:
@@ -124,6 +188,10 @@ func TestReadPages(t *testing.T) {
 				t.Errorf("StripFuncs output %v != %v", p.Settings.StripFuncs, expectedP.Settings.StripFuncs)
 			case !isHeadersEqual(p.Settings.Header, expectedP.Settings.Header):
 				t.Errorf("Header output %v != %v", p.Settings.Header, expectedP.Settings.Header)
+			case p.Settings.Method != expectedP.Settings.Method:
+				t.Errorf("Method output %v != %v", p.Settings.Method, expectedP.Settings.Method)
+			case p.Settings.Body != expectedP.Settings.Body:
+				t.Errorf("Body output %v != %v", p.Settings.Body, expectedP.Settings.Body)
 			default:
 				pageFound = true
 				break
@@ -0,0 +1,135 @@
+package ini
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/karlek/nyfiken/settings"
+	"github.com/mewbak/ini"
+	"github.com/mewkiz/pkg/errutil"
+)
+
+// Prefix of login recipe sections in config.ini (i.e. [login.name]).
+const sectionLoginPrefix = "login."
+
+// Login recipe field names. In page sections they are prefixed with
+// fieldLogin, e.g. `loginurl`.
+const (
+	fieldLogin      = "login"
+	fieldLoginURL   = "url"
+	fieldLoginForm  = "form"
+	fieldLoginField = "field"
+	fieldLoginMatch = "match"
+)
+
+// Valid fields in login recipe sections.
+var loginFields = map[string]bool{
+	fieldLoginURL:   true,
+	fieldLoginForm:  true,
+	fieldLoginField: true,
+	fieldLoginMatch: true,
+}
+
+// Error messages.
+var (
+	errLoginNotExist     = "ini: login `%s` doesn't exist in config.ini."
+	errLoginURLNotFound  = "ini: login `%s`: login page URL required."
+	errInvalidLoginField = "ini: login `%s`: invalid field: `%s`; correct syntax -> `name=value`."
+	errMultipleLogins    = "ini: use either `" + fieldLogin + "` or `" + fieldLogin + fieldLoginURL + "`, not both."
+)
+
+// parseLogins parses all [login.name] sections of config.ini to named login
+// recipes.
+func parseLogins(sections map[string]ini.Section) (logins map[string]*settings.Login, err error) {
+	logins = make(map[string]*settings.Login)
+	for sectionName, section := range sections {
+		if !strings.HasPrefix(sectionName, sectionLoginPrefix) {
+			continue
+		}
+		for fieldName := range section {
+			if _, found := loginFields[fieldName]; !found {
+				return nil, errutil.NewNoPosf(errFieldNotExist, fieldName)
+			}
+		}
+
+		name := strings.TrimPrefix(sectionName, sectionLoginPrefix)
+		logins[name], err = parseLogin(name, section, "")
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+	}
+	return logins, nil
+}
+
+// pageLogin returns the login recipe of a page: either a named recipe from
+// config.ini referenced by `login = name`, or an inline recipe using prefixed
+// field names (e.g. `loginurl`). It returns nil if the page doesn't log in.
+func pageLogin(section ini.Section, jarName string) (l *settings.Login, err error) {
+	name := section.S(fieldLogin, "")
+	inline := section.S(fieldLogin+fieldLoginURL, "") != ""
+	switch {
+	case name != "" && inline:
+		return nil, errutil.NewNoPosf(errMultipleLogins)
+	case name != "":
+		l, found := settings.Global.Logins[name]
+		if !found {
+			return nil, errutil.NewNoPosf(errLoginNotExist, name)
+		}
+		return l, nil
+	case inline:
+		return parseLogin(jarName, section, fieldLogin)
+	}
+	return nil, nil
+}
+
+// parseLogin parses a login recipe from the fields of a section, whose names
+// are prefixed with prefix.
+func parseLogin(name string, section ini.Section, prefix string) (l *settings.Login, err error) {
+	l = &settings.Login{Name: name}
+
+	// Set URL of the login page.
+	l.URL = section.S(prefix+fieldLoginURL, "")
+	if l.URL == "" {
+		return nil, errutil.NewNoPosf(errLoginURLNotFound, name)
+	}
+	if _, err = url.Parse(l.URL); err != nil {
+		return nil, errutil.Err(err)
+	}
+
+	// Set CSS selector of the login form.
+	l.Form = section.S(prefix+fieldLoginForm, "")
+	if l.Form != "" {
+		if _, err = cascadia.Compile(l.Form); err != nil {
+			return nil, errutil.Err(err)
+		}
+	}
+
+	// Set regular expression matching the login page.
+	l.Match = section.S(prefix+fieldLoginMatch, "")
+	if _, err = regexp.Compile(l.Match); err != nil {
+		return nil, errutil.Err(err)
+	}
+
+	// Set form fields to submit, e.g. credentials.
+	fields := section.List(prefix + fieldLoginField)
+	if fields == nil {
+		if _, found := section[prefix+fieldLoginField]; found {
+			return nil, errutil.NewNoPosf(errInvalidListDeclaration)
+		}
+	}
+	l.Fields = make(map[string]string)
+	for _, field := range fields {
+		if !strings.Contains(field, "=") {
+			return nil, errutil.NewNoPosf(errInvalidLoginField, name, field)
+		}
+		keyVal := strings.SplitN(field, "=", 2)
+		l.Fields[strings.TrimSpace(keyVal[0])], err = resolveNamedSecret(keyVal[0], strings.TrimSpace(keyVal[1]))
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+	}
+
+	return l, nil
+}
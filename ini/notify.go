@@ -0,0 +1,106 @@
+package ini
+
+import (
+	"strings"
+
+	"github.com/karlek/nyfiken/notify"
+	"github.com/karlek/nyfiken/settings"
+	"github.com/mewbak/ini"
+	"github.com/mewkiz/pkg/errutil"
+)
+
+// Prefix of notifier sections in config.ini (i.e. [notify.name]).
+const sectionNotifyPrefix = "notify."
+
+// Notifier field names.
+const (
+	fieldNotify = "notify"
+)
+
+// Error messages.
+var (
+	errNotifierNoType = "ini: notifier `%s`: field `" + notify.FieldType + "` required."
+	errInvalidNotify  = "ini: invalid notifier `%s`; %v"
+)
+
+// parseNotifiers parses all [notify.name] sections of config.ini to named
+// notifiers. Secret references in their fields are resolved.
+func parseNotifiers(sections map[string]ini.Section) (notifiers map[string]*settings.Notifier, err error) {
+	notifiers = make(map[string]*settings.Notifier)
+	for sectionName, section := range sections {
+		if !strings.HasPrefix(sectionName, sectionNotifyPrefix) {
+			continue
+		}
+
+		name := strings.TrimPrefix(sectionName, sectionNotifyPrefix)
+		conf := &settings.Notifier{
+			Name:   name,
+			Type:   section.S(notify.FieldType, ""),
+			Fields: make(map[string]string),
+			Lists:  make(map[string][]string),
+		}
+		if conf.Type == "" {
+			return nil, errutil.NewNoPosf(errNotifierNoType, name)
+		}
+		for fieldName := range section {
+			if fieldName == notify.FieldType {
+				continue
+			}
+			if list := section.List(fieldName); list != nil {
+				// Resolve secret references in values of secret headers.
+				if fieldName == fieldHeader {
+					list, err = resolveHeaders(list)
+					if err != nil {
+						return nil, errutil.Err(err)
+					}
+				}
+				conf.Lists[fieldName] = list
+				continue
+			}
+			conf.Fields[fieldName], err = resolveNamedSecret(fieldName, section.S(fieldName, ""))
+			if err != nil {
+				return nil, errutil.Err(err)
+			}
+		}
+
+		// Validate the configuration.
+		if _, err = notify.New(conf); err != nil {
+			return nil, errutil.NewNoPosf(errInvalidNotify, name, err)
+		}
+		notifiers[name] = conf
+	}
+	return notifiers, nil
+}
+
+// pageNotifiers returns the names of the notifiers of a page, which must exist
+// in config.ini.
+func pageNotifiers(section ini.Section) (names []string, err error) {
+	names = section.List(fieldNotify)
+	if names == nil {
+		if _, found := section[fieldNotify]; found {
+			return nil, errutil.NewNoPosf(errInvalidListDeclaration)
+		}
+	}
+	for _, name := range names {
+		if _, found := settings.Global.Notifiers[name]; !found {
+			return nil, errutil.NewNoPosf(errInvalidNotify, name, "doesn't exist in config.ini")
+		}
+	}
+	return names, nil
+}
+
+// resolveHeaders resolves secret references in the values of headers with
+// secret names (e.g. `Authorization: env:TOKEN`).
+func resolveHeaders(headers []string) (resolved []string, err error) {
+	for _, header := range headers {
+		if keyVal := strings.SplitN(header, ":", 2); len(keyVal) == 2 {
+			val, err := resolveNamedSecret(keyVal[0], strings.TrimSpace(keyVal[1]))
+			if err != nil {
+				return nil, errutil.Err(err)
+			}
+			header = keyVal[0] + ": " + val
+		}
+		resolved = append(resolved, header)
+	}
+	return resolved, nil
+}
@@ -0,0 +1,78 @@
+package ini
+
+import (
+	"strings"
+
+	"github.com/karlek/nyfiken/mail"
+	"github.com/karlek/nyfiken/settings"
+	"github.com/mewbak/ini"
+	"github.com/mewkiz/pkg/errutil"
+)
+
+// Prefix of recipient group sections in config.ini (i.e. [group.name]).
+const sectionGroupPrefix = "group."
+
+// Recipient field names.
+const (
+	fieldCC = "cc"
+)
+
+// Error messages.
+var (
+	errInvalidGroup = "ini: invalid recipient group `%s`; %v"
+	errEmptyGroup   = "ini: recipient group `%s` has no `" + fieldRecvMail + "` entries."
+)
+
+// parseGroups parses all [group.name] sections of config.ini to named groups of
+// mail addresses, listed with `recvmail < address`.
+func parseGroups(sections map[string]ini.Section) (groups map[string][]string, err error) {
+	groups = make(map[string][]string)
+	for sectionName, section := range sections {
+		if !strings.HasPrefix(sectionName, sectionGroupPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(sectionName, sectionGroupPrefix)
+		for fieldName := range section {
+			if fieldName != fieldRecvMail {
+				return nil, errutil.NewNoPosf(errFieldNotExist, fieldName)
+			}
+		}
+		entries := listOrValue(section, fieldRecvMail)
+		// Groups may not contain groups.
+		groups[name], err = mail.ParseRecipients(entries, nil)
+		if err != nil {
+			return nil, errutil.NewNoPosf(errInvalidGroup, name, err)
+		}
+		if len(groups[name]) == 0 {
+			return nil, errutil.NewNoPosf(errEmptyGroup, name)
+		}
+	}
+	return groups, nil
+}
+
+// parseRecipients parses the recipients of a field, which are mail addresses
+// (e.g. `Alice <alice@example.org>`) or names of recipient groups, given as a
+// single value or a list. It returns def if the field is missing.
+func parseRecipients(section ini.Section, field string, def []string) (addrs []string, err error) {
+	entries := listOrValue(section, field)
+	if entries == nil {
+		return def, nil
+	}
+	addrs, err = mail.ParseRecipients(entries, settings.Global.Groups)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	return addrs, nil
+}
+
+// listOrValue returns the values of a field which is either a list or a
+// single value, or nil if the field is missing.
+func listOrValue(section ini.Section, field string) (values []string) {
+	if list := section.List(field); list != nil {
+		return list
+	}
+	if _, found := section[field]; !found {
+		return nil
+	}
+	return []string{section.S(field, "")}
+}
@@ -0,0 +1,134 @@
+package ini
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/karlek/nyfiken/filter"
+	"github.com/karlek/nyfiken/settings"
+	"github.com/mewbak/ini"
+	"github.com/mewkiz/pkg/errutil"
+)
+
+// Prefixes of secret references, which are resolved when the settings are
+// loaded, e.g. `sendpass = env:SMTP_PASS`.
+const (
+	// secretEnv reads the secret from an environment variable.
+	secretEnv = "env:"
+	// secretFile reads the secret from a file, e.g. a Docker secret.
+	secretFile = "file:"
+	// secretCmd reads the secret from the output of a command, e.g. a password
+	// manager.
+	secretCmd = "cmd:"
+)
+
+// secretNameExp matches the names of form fields and headers which contain
+// secrets.
+var secretNameExp = regexp.MustCompile(`(?i)pass|secret|token|key|auth|cookie|session`)
+
+// Error messages.
+var (
+	errSecretEnvNotSet = "ini: secret environment variable `%s` is not set."
+	errSecretCmd       = "ini: secret command: %v"
+	warnPlainSecrets   = "ini: warning: %s contains plaintext secrets and may be read by other users; use secret references (e.g. `env:NAME`) or `chmod 600 %s`."
+)
+
+// resolveSecret returns the secret referenced by val, which may be read from an
+// environment variable (`env:NAME`), a file (`file:/path`) or the output of a
+// command (`cmd:pass show smtp`). Values without a reference are returned as
+// is.
+func resolveSecret(val string) (secret string, err error) {
+	switch {
+	case strings.HasPrefix(val, secretEnv):
+		name := strings.TrimSpace(strings.TrimPrefix(val, secretEnv))
+		secret, found := os.LookupEnv(name)
+		if !found {
+			return "", errutil.NewNoPosf(errSecretEnvNotSet, name)
+		}
+		return secret, nil
+	case strings.HasPrefix(val, secretFile):
+		buf, err := ioutil.ReadFile(strings.TrimSpace(strings.TrimPrefix(val, secretFile)))
+		if err != nil {
+			return "", errutil.Err(err)
+		}
+		return strings.TrimRight(string(buf), "\r\n"), nil
+	case strings.HasPrefix(val, secretCmd):
+		cmd, err := filter.Parse(val)
+		if err != nil {
+			return "", errutil.NewNoPosf(errSecretCmd, err)
+		}
+		out, err := cmd.Run("", settings.DefaultFilterTimeout)
+		if err != nil {
+			return "", errutil.NewNoPosf(errSecretCmd, err)
+		}
+		return strings.TrimRight(out, "\r\n"), nil
+	}
+	return val, nil
+}
+
+// resolveNamedSecret resolves the secret referenced by the value of a field or
+// header, if its name is the name of a secret. Values of other fields are
+// returned as is, so that e.g. a template starting with `env:` isn't mistaken
+// for a secret reference.
+func resolveNamedSecret(name, val string) (secret string, err error) {
+	if !secretNameExp.MatchString(name) {
+		return val, nil
+	}
+	return resolveSecret(val)
+}
+
+// isSecretRef reports whether val is a secret reference.
+func isSecretRef(val string) bool {
+	return strings.HasPrefix(val, secretEnv) ||
+		strings.HasPrefix(val, secretFile) ||
+		strings.HasPrefix(val, secretCmd)
+}
+
+// hasPlainSecrets reports whether the sections contain secrets which are not
+// secret references, i.e. mail passwords, and form fields and headers with
+// secret names.
+func hasPlainSecrets(sections map[string]ini.Section) bool {
+	for _, section := range sections {
+		if val := section.S(fieldSendPass, ""); val != "" && !isSecretRef(val) {
+			return true
+		}
+		lists := map[string]string{
+			fieldLoginField:              "=",
+			fieldLogin + fieldLoginField: "=",
+			fieldHeader:                  ":",
+		}
+		for fieldName, sep := range lists {
+			for _, keyVal := range section.List(fieldName) {
+				kv := strings.SplitN(keyVal, sep, 2)
+				if len(kv) != 2 {
+					continue
+				}
+				val := strings.TrimSpace(kv[1])
+				if secretNameExp.MatchString(kv[0]) && val != "" && !isSecretRef(val) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// warnInsecure logs a warning if the file at path contains plaintext
+// secrets and may be read by other users.
+func warnInsecure(path string, sections map[string]ini.Section) {
+	// Windows doesn't use Unix permission bits.
+	if runtime.GOOS == "windows" || !hasPlainSecrets(sections) {
+		return
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if fi.Mode().Perm()&0004 != 0 {
+		log.Printf(warnPlainSecrets, path, path)
+	}
+}
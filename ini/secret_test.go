@@ -0,0 +1,79 @@
+package ini
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// Tests resolveSecret
+func TestResolveSecret(t *testing.T) {
+	os.Setenv("NYFIKEN_TEST_SECRET", "from-env")
+	defer os.Unsetenv("NYFIKEN_TEST_SECRET")
+
+	f, err := ioutil.TempFile("", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.WriteString("from-file\n"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	golden := []struct {
+		in   string
+		want string
+	}{
+		{in: "plaintext", want: "plaintext"},
+		{in: "env:NYFIKEN_TEST_SECRET", want: "from-env"},
+		{in: "file:" + f.Name(), want: "from-file"},
+		{in: "cmd:echo 'from cmd'", want: "from cmd"},
+	}
+	for _, g := range golden {
+		got, err := resolveSecret(g.in)
+		if err != nil {
+			t.Errorf("%s: %s", g.in, err)
+			continue
+		}
+		if got != g.want {
+			t.Errorf("output `%v` != expected `%v`", got, g.want)
+		}
+	}
+
+	for _, in := range []string{"env:NYFIKEN_TEST_UNSET", "file:" + f.Name() + ".missing", "cmd:false"} {
+		if _, err := resolveSecret(in); err == nil {
+			t.Errorf("%s: expected error", in)
+		}
+	}
+}
+
+// Tests resolveNamedSecret
+func TestResolveNamedSecret(t *testing.T) {
+	os.Setenv("NYFIKEN_TEST_SECRET", "from-env")
+	defer os.Unsetenv("NYFIKEN_TEST_SECRET")
+
+	golden := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "password", in: "env:NYFIKEN_TEST_SECRET", want: "from-env"},
+		{name: "Authorization", in: "env:NYFIKEN_TEST_SECRET", want: "from-env"},
+		{name: "Cookie", in: "env:NYFIKEN_TEST_SECRET", want: "from-env"},
+		{name: "secret", in: "env:NYFIKEN_TEST_SECRET", want: "from-env"},
+		{name: "template", in: "env: {{.Title}}", want: "env: {{.Title}}"},
+		{name: "X-Source", in: "file:report", want: "file:report"},
+		{name: "command", in: "cmd:false", want: "cmd:false"},
+	}
+	for _, g := range golden {
+		got, err := resolveNamedSecret(g.name, g.in)
+		if err != nil {
+			t.Errorf("%s: %s", g.name, err)
+			continue
+		}
+		if got != g.want {
+			t.Errorf("output `%v` != expected `%v`", got, g.want)
+		}
+	}
+}
@@ -0,0 +1,324 @@
+// Package jar implements cookie jars which are persisted on disk, so that
+// sessions and consent cookies survive between checks and restarts of
+// nyfikend.
+package jar
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/karlek/nyfiken/filename"
+	"github.com/karlek/nyfiken/settings"
+	"github.com/mewkiz/pkg/errutil"
+	"golang.org/x/net/publicsuffix"
+)
+
+// Jar is a cookie jar persisted under settings.JarRoot. It is safe for
+// concurrent use.
+type Jar struct {
+	Name string // Name of the jar; jars with the same name are shared.
+
+	mu      sync.Mutex
+	jar     *cookiejar.Jar
+	entries []entry
+}
+
+// An entry is a cookie together with the URL which set it. The entries are
+// replayed into a new cookiejar.Jar when the jar is loaded from disk.
+type entry struct {
+	URL    string
+	Cookie *http.Cookie
+}
+
+// file is the on-disk representation of a jar.
+type file struct {
+	Name    string
+	Entries []entry
+}
+
+// Extension of jar files.
+const ext = ".json"
+
+var (
+	// mu protects jars.
+	mu sync.Mutex
+
+	// jars maps names to open jars.
+	jars = make(map[string]*Jar)
+)
+
+// Get returns the jar by the provided name, loading it from disk if it hasn't
+// been opened before.
+func Get(name string) (j *Jar, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if j, found := jars[name]; found {
+		return j, nil
+	}
+
+	j = &Jar{Name: name}
+	err = j.load()
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	jars[name] = j
+	return j, nil
+}
+
+// All returns the cookies of all jars, both open and on disk, by jar name.
+func All() (cookies map[string][]*http.Cookie, err error) {
+	names, err := names()
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	cookies = make(map[string][]*http.Cookie)
+	for _, name := range names {
+		j, err := Get(name)
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+		cookies[name] = j.All()
+	}
+	return cookies, nil
+}
+
+// Clear removes all cookies from the jar by the provided name, or from all
+// jars if name is empty. The jars are removed from disk.
+func Clear(name string) (err error) {
+	names, err := names()
+	if err != nil {
+		return errutil.Err(err)
+	}
+	for _, n := range names {
+		if name != "" && n != name {
+			continue
+		}
+		j, err := Get(n)
+		if err != nil {
+			return errutil.Err(err)
+		}
+		err = j.Clear()
+		if err != nil {
+			return errutil.Err(err)
+		}
+	}
+	return nil
+}
+
+// names returns the names of all open jars and jars on disk.
+func names() (names []string, err error) {
+	found := make(map[string]bool)
+	mu.Lock()
+	for name := range jars {
+		found[name] = true
+	}
+	mu.Unlock()
+
+	fis, err := ioutil.ReadDir(settings.JarRoot)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errutil.Err(err)
+	}
+	for _, fi := range fis {
+		if !strings.HasSuffix(fi.Name(), ext) {
+			continue
+		}
+		buf, err := ioutil.ReadFile(settings.JarRoot + fi.Name())
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+		var f file
+		if err = json.Unmarshal(buf, &f); err != nil || f.Name == "" {
+			continue
+		}
+		found[f.Name] = true
+	}
+
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// All returns all unexpired cookies of the jar. The domain of host-only
+// cookies is set to the host which set them.
+func (j *Jar) All() (cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	for _, e := range j.entries {
+		c := *e.Cookie
+		if !c.Expires.IsZero() && c.Expires.Before(time.Now()) {
+			continue
+		}
+		if c.Domain == "" {
+			if u, err := url.Parse(e.URL); err == nil {
+				c.Domain = u.Host
+			}
+		}
+		cookies = append(cookies, &c)
+	}
+	return cookies
+}
+
+// Clear removes all cookies from the jar and its file from disk.
+func (j *Jar) Clear() (err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.jar, err = cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return errutil.Err(err)
+	}
+	j.entries = nil
+
+	path, err := j.path()
+	if err != nil {
+		return errutil.Err(err)
+	}
+	err = os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return errutil.Err(err)
+	}
+	return nil
+}
+
+// SetCookies implements the http.CookieJar interface.
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.jar.SetCookies(u, cookies)
+	for _, cookie := range cookies {
+		j.record(u, cookie)
+	}
+}
+
+// Cookies implements the http.CookieJar interface.
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.jar.Cookies(u)
+}
+
+// Save writes the cookies of the jar to disk.
+func (j *Jar) Save() (err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	path, err := j.path()
+	if err != nil {
+		return errutil.Err(err)
+	}
+	buf, err := json.Marshal(file{Name: j.Name, Entries: j.entries})
+	if err != nil {
+		return errutil.Err(err)
+	}
+	// Cookies may contain session tokens, so only the user may read them.
+	err = ioutil.WriteFile(path, buf, 0600)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	return nil
+}
+
+// record remembers a cookie set by u, replacing any previous cookie with the
+// same name, domain and path. Relative expiry times are made absolute, and
+// cookies without a path are given the default path of u, so that the cookie
+// is sent to the same URLs and expires as intended after being loaded.
+func (j *Jar) record(u *url.URL, cookie *http.Cookie) {
+	c := *cookie
+	if !strings.HasPrefix(c.Path, "/") {
+		c.Path = defaultPath(u)
+	}
+	if c.MaxAge > 0 {
+		c.Expires = time.Now().Add(time.Duration(c.MaxAge) * time.Second)
+		c.MaxAge = 0
+	}
+	e := entry{URL: u.Scheme + "://" + u.Host + "/", Cookie: &c}
+
+	var entries []entry
+	for _, old := range j.entries {
+		if !sameCookie(old, e) {
+			entries = append(entries, old)
+		}
+	}
+	// Deleted cookies are forgotten.
+	if c.MaxAge >= 0 && (c.Expires.IsZero() || c.Expires.After(time.Now())) {
+		entries = append(entries, e)
+	}
+	j.entries = entries
+}
+
+// defaultPath returns the default path of cookies set by u, as defined by RFC
+// 6265 section 5.1.4; i.e. the directory of its path.
+func defaultPath(u *url.URL) string {
+	i := strings.LastIndex(u.Path, "/")
+	if !strings.HasPrefix(u.Path, "/") || i == 0 {
+		return "/"
+	}
+	return u.Path[:i]
+}
+
+// sameCookie reports whether a and b refer to the same cookie.
+func sameCookie(a, b entry) bool {
+	return a.Cookie.Name == b.Cookie.Name &&
+		a.Cookie.Path == b.Cookie.Path &&
+		a.Cookie.Domain == b.Cookie.Domain &&
+		(a.Cookie.Domain != "" || a.URL == b.URL)
+}
+
+// load reads the cookies of the jar from disk. A missing file results in an
+// empty jar.
+func (j *Jar) load() (err error) {
+	j.jar, err = cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return errutil.Err(err)
+	}
+	j.entries = nil
+
+	path, err := j.path()
+	if err != nil {
+		return errutil.Err(err)
+	}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errutil.Err(err)
+	}
+	var f file
+	err = json.Unmarshal(buf, &f)
+	if err != nil {
+		return errutil.Err(err)
+	}
+
+	for _, e := range f.Entries {
+		u, err := url.Parse(e.URL)
+		if err != nil || e.Cookie == nil {
+			continue
+		}
+		j.jar.SetCookies(u, []*http.Cookie{e.Cookie})
+		j.record(u, e.Cookie)
+	}
+	return nil
+}
+
+// path returns the path of the file in which the jar is persisted.
+func (j *Jar) path() (path string, err error) {
+	name, err := filename.Encode(j.Name)
+	if err != nil {
+		return "", errutil.Err(err)
+	}
+	return settings.JarRoot + name + ext, nil
+}
@@ -0,0 +1,129 @@
+package jar
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/karlek/nyfiken/settings"
+)
+
+func TestJar(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nyfiken-jar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	settings.JarRoot = dir + "/"
+
+	u, _ := url.Parse("http://example.org/login")
+	j, err := Get("example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "abc", Path: "/"},
+		{Name: "expired", Value: "xyz", Path: "/", MaxAge: -1},
+	})
+	err = j.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reload the jar from disk.
+	delete(jars, "example")
+	all, err := All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := all["example"]
+	if len(cookies) != 1 {
+		t.Fatalf("output `%v` != expected `%v`", len(cookies), 1)
+	}
+	if cookies[0].Name != "session" || cookies[0].Value != "abc" || cookies[0].Domain != "example.org" {
+		t.Errorf("output `%v` != expected `%v`", cookies[0], "session=abc; Domain=example.org")
+	}
+
+	err = Clear("example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	all, err = All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all["example"]) != 0 {
+		t.Errorf("output `%v` != expected `%v`", len(all["example"]), 0)
+	}
+	if _, err = os.Stat(settings.JarRoot + "example" + ext); !os.IsNotExist(err) {
+		t.Errorf("output `%v` != expected `%v`", err, "not exist")
+	}
+}
+
+// Tests that cookies without a path are only sent to the path of the URL
+// which set them after being loaded.
+func TestJarPath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nyfiken-jar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	settings.JarRoot = dir + "/"
+
+	u, _ := url.Parse("http://example.org/account/login")
+	j, err := Get("path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	j.SetCookies(u, []*http.Cookie{{Name: "session", Value: "abc"}})
+	err = j.Save()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reload the jar from disk.
+	delete(jars, "path")
+	j, err = Get("path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var testTable = []struct {
+		rawurl   string
+		expected int
+	}{
+		{"http://example.org/account/settings", 1},
+		{"http://example.org/account", 1},
+		{"http://example.org/", 0},
+		{"http://example.org/other", 0},
+	}
+	for _, test := range testTable {
+		u, _ := url.Parse(test.rawurl)
+		output := len(j.Cookies(u))
+		if output != test.expected {
+			t.Errorf("%s: output `%v` != expected `%v`", test.rawurl, output, test.expected)
+		}
+	}
+}
+
+// Tests defaultPath
+func TestDefaultPath(t *testing.T) {
+	var testTable = []struct {
+		rawurl   string
+		expected string
+	}{
+		{"http://example.org", "/"},
+		{"http://example.org/", "/"},
+		{"http://example.org/login", "/"},
+		{"http://example.org/account/login", "/account"},
+		{"http://example.org/a/b/", "/a/b"},
+	}
+	for _, test := range testTable {
+		u, _ := url.Parse(test.rawurl)
+		output := defaultPath(u)
+		if output != test.expected {
+			t.Errorf("output `%v` != expected `%v`", output, test.expected)
+		}
+	}
+}
@@ -0,0 +1,263 @@
+// Package login logs in to sites before their pages are checked, by submitting
+// their login forms on behalf of the user.
+package login
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/karlek/nyfiken/settings"
+	"github.com/mewkiz/pkg/errutil"
+	"golang.org/x/net/html"
+)
+
+// Error messages.
+var (
+	errNoForm      = "login: no login form found at %s."
+	errLoginFailed = "login: login at %s failed: (%d) - %s"
+	errStillLogin  = "login: login at %s failed: still at the login page %s."
+)
+
+// Default selector of the login form: the first form with a password input.
+const defaultForm = "form:has(input[type=password])"
+
+var (
+	// mu protects locks.
+	mu sync.Mutex
+
+	// locks serializes logins using the same recipe, since pages sharing a
+	// recipe may be checked concurrently.
+	locks = make(map[string]*sync.Mutex)
+)
+
+// Required reports whether the response indicates that the session of the
+// login recipe l has expired, i.e. the request was redirected to the login page
+// of the recipe. Responses denying access aren't considered, since a site may
+// deny access to a page for other reasons than an expired session.
+func Required(resp *http.Response, l *settings.Login) bool {
+	if resp.Request == nil {
+		return false
+	}
+	return isLoginPage(resp.Request.URL, l)
+}
+
+// Do logs in using the login recipe l. The login form is fetched, its hidden
+// fields (e.g. CSRF tokens) are merged with the fields of the recipe and the
+// form is submitted. Cookies are stored in the cookie jar of the client.
+func Do(client *http.Client, l *settings.Login) (err error) {
+	lock := recipeLock(l.Name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if settings.Verbose {
+		fmt.Println("[/] Logging in:", l.URL)
+	}
+
+	// Fetch the login form.
+	resp, err := client.Get(l.URL)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return errutil.NewNoPosf(errLoginFailed, l.URL, resp.StatusCode, resp.Status)
+	}
+	doc, err := html.Parse(io.LimitReader(resp.Body, settings.DefaultMaxBodySize))
+	if err != nil {
+		return errutil.Err(err)
+	}
+
+	sel := l.Form
+	if sel == "" {
+		sel = defaultForm
+	}
+	s, err := cascadia.Compile(sel)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	form := s.MatchFirst(doc)
+	if form == nil && l.Form == "" {
+		// Fall back to the first form of the page.
+		form = cascadia.MustCompile("form").MatchFirst(doc)
+	}
+	if form == nil {
+		return errutil.NewNoPosf(errNoForm, l.URL)
+	}
+
+	// Merge the current form values with the fields of the recipe.
+	values := formValues(form)
+	for key, val := range l.Fields {
+		values.Set(key, val)
+	}
+
+	// Submit the form to its action, relative to the final URL of the login
+	// page.
+	action, err := resp.Request.URL.Parse(attr(form, "action"))
+	if err != nil {
+		return errutil.Err(err)
+	}
+	var req *http.Request
+	if strings.EqualFold(attr(form, "method"), "get") {
+		action.RawQuery = values.Encode()
+		req, err = http.NewRequest("GET", action.String(), nil)
+	} else {
+		req, err = http.NewRequest("POST", action.String(), strings.NewReader(values.Encode()))
+		if req != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if err != nil {
+		return errutil.Err(err)
+	}
+	req.Header.Set("Referer", resp.Request.URL.String())
+
+	res, err := client.Do(req)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 400 {
+		return errutil.NewNoPosf(errLoginFailed, l.URL, res.StatusCode, res.Status)
+	}
+	// Login forms are usually shown again when the credentials were wrong.
+	if isLoginPage(res.Request.URL, l) {
+		doc, err := html.Parse(io.LimitReader(res.Body, settings.DefaultMaxBodySize))
+		if err != nil {
+			return errutil.Err(err)
+		}
+		if cascadia.MustCompile(defaultForm).MatchFirst(doc) != nil {
+			return errutil.NewNoPosf(errStillLogin, l.URL, res.Request.URL)
+		}
+	}
+
+	return nil
+}
+
+// isLoginPage reports whether u is the login page of the login recipe l. Unless
+// the recipe specifies a regular expression to match, the URL is compared to
+// the URL of the recipe, ignoring the query string.
+func isLoginPage(u *url.URL, l *settings.Login) bool {
+	if l.Match != "" {
+		re, err := regexp.Compile(l.Match)
+		if err != nil {
+			return false
+		}
+		return re.MatchString(u.String())
+	}
+	loginUrl, err := url.Parse(l.URL)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, loginUrl.Host) && strings.TrimSuffix(u.Path, "/") == strings.TrimSuffix(loginUrl.Path, "/")
+}
+
+// formValues returns the values which a browser would submit for the form,
+// e.g. hidden CSRF tokens and prefilled inputs.
+func formValues(form *html.Node) url.Values {
+	values := url.Values{}
+	var f func(node *html.Node)
+	f = func(node *html.Node) {
+		if node.Type == html.ElementNode {
+			name := attr(node, "name")
+			switch {
+			case name == "" || hasAttr(node, "disabled"):
+			case node.Data == "input":
+				switch strings.ToLower(attr(node, "type")) {
+				case "submit", "button", "image", "reset", "file":
+				case "checkbox", "radio":
+					if hasAttr(node, "checked") {
+						val := attr(node, "value")
+						if val == "" && !hasAttr(node, "value") {
+							val = "on"
+						}
+						values.Add(name, val)
+					}
+				default:
+					values.Add(name, attr(node, "value"))
+				}
+			case node.Data == "textarea":
+				values.Add(name, text(node))
+			case node.Data == "select":
+				if val, ok := selected(node); ok {
+					values.Add(name, val)
+				}
+			}
+		}
+
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(form)
+	return values
+}
+
+// selected returns the value of the selected option of a select element, or of
+// its first option if none is selected.
+func selected(node *html.Node) (val string, ok bool) {
+	options := cascadia.MustCompile("option").MatchAll(node)
+	if len(options) == 0 {
+		return "", false
+	}
+	option := options[0]
+	for _, o := range options {
+		if hasAttr(o, "selected") {
+			option = o
+			break
+		}
+	}
+	if hasAttr(option, "value") {
+		return attr(option, "value"), true
+	}
+	return strings.TrimSpace(text(option)), true
+}
+
+// recipeLock returns the lock of a login recipe.
+func recipeLock(name string) *sync.Mutex {
+	mu.Lock()
+	defer mu.Unlock()
+	lock, found := locks[name]
+	if !found {
+		lock = new(sync.Mutex)
+		locks[name] = lock
+	}
+	return lock
+}
+
+// text returns the concatenated text of all text nodes in a node.
+func text(node *html.Node) string {
+	if node.Type == html.TextNode {
+		return node.Data
+	}
+	var s string
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		s += text(c)
+	}
+	return s
+}
+
+// attr returns the value of the attribute key of a node.
+func attr(node *html.Node, key string) string {
+	for _, a := range node.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// hasAttr reports whether a node has the attribute key.
+func hasAttr(node *html.Node, key string) bool {
+	for _, a := range node.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package login
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/karlek/nyfiken/settings"
+)
+
+// newServer returns a test server with a login form protected by a CSRF token,
+// and a secret page which redirects to the login form unless logged in.
+func newServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" &&
+			r.FormValue("csrf") == "t0k3n" &&
+			r.FormValue("user") == "nyfiken" &&
+			r.FormValue("pass") == "secret" &&
+			r.FormValue("remember") == "yes" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "42"})
+			http.Redirect(w, r, "/secret", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, `<html><body><form id="search"><input name="q"></form>
+<form method="post" action="/login">
+	<input type="hidden" name="csrf" value="t0k3n">
+	<input name="user"><input type="password" name="pass">
+	<select name="remember"><option>no</option><option value="yes" selected>Yes</option></select>
+	<input type="submit" name="submit" value="Log in">
+</form></body></html>`)
+	})
+	mux.HandleFunc("/forbidden", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+	})
+	mux.HandleFunc("/secret", func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("session"); err != nil || c.Value != "42" {
+			http.Redirect(w, r, "/login", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, "love")
+	})
+	return httptest.NewServer(mux)
+}
+
+// Tests Required and Do
+func TestDo(t *testing.T) {
+	ts := newServer()
+	defer ts.Close()
+
+	var testTable = []struct {
+		pass  string
+		valid bool
+	}{
+		{"secret", true},
+		{"wrong", false},
+	}
+
+	for _, test := range testTable {
+		j, err := cookiejar.New(nil)
+		if err != nil {
+			t.Fatalf("cookiejar.New: %s", err)
+		}
+		client := &http.Client{Jar: j}
+		l := &settings.Login{
+			Name:   "test-" + test.pass,
+			URL:    ts.URL + "/login",
+			Fields: map[string]string{"user": "nyfiken", "pass": test.pass},
+		}
+
+		// The secret page redirects to the login page before logging in.
+		resp, err := client.Get(ts.URL + "/secret")
+		if err != nil {
+			t.Fatalf("Get: %s", err)
+		}
+		resp.Body.Close()
+		if !Required(resp, l) {
+			t.Errorf("%s: expected login to be required", test.pass)
+		}
+
+		err = Do(client, l)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%s: expected error", test.pass)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.pass, err)
+			continue
+		}
+
+		resp, err = client.Get(ts.URL + "/secret")
+		if err != nil {
+			t.Fatalf("Get: %s", err)
+		}
+		buf, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("ReadAll: %s", err)
+		}
+		if Required(resp, l) {
+			t.Errorf("%s: expected login not to be required", test.pass)
+		}
+		if string(buf) != "love" {
+			t.Errorf("output `%v` != expected `%v`", string(buf), "love")
+		}
+	}
+}
+
+// Tests that responses denying access don't require a login, unless they are
+// the login page
+func TestRequiredDenied(t *testing.T) {
+	ts := newServer()
+	defer ts.Close()
+
+	l := &settings.Login{Name: "test-denied", URL: ts.URL + "/login"}
+	resp, err := http.Get(ts.URL + "/forbidden")
+	if err != nil {
+		t.Fatalf("Get: %s", err)
+	}
+	resp.Body.Close()
+	if Required(resp, l) {
+		t.Errorf("expected login not to be required")
+	}
+
+	l.Match = "/forbidden$"
+	if !Required(resp, l) {
+		t.Errorf("expected login to be required")
+	}
+}
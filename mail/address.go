@@ -0,0 +1,70 @@
+package mail
+
+import (
+	netmail "net/mail"
+	"strings"
+
+	"github.com/mewkiz/pkg/errutil"
+)
+
+// Error messages.
+var (
+	errInvalidRecipient = "mail: invalid mail: `%s`; correct syntax -> `name@domain.tld`, `Name <name@domain.tld>` or the name of a recipient group."
+)
+
+// ParseRecipients parses recipients according to RFC 5322. Each entry is
+// either an address list, e.g. `Alice <alice@example.org>, bob@example.org`,
+// or the name of one of the recipient groups. The addresses are returned
+// formatted for mail headers, without duplicates.
+func ParseRecipients(entries []string, groups map[string][]string) (addrs []string, err error) {
+	seen := make(map[string]bool)
+	add := func(addr *netmail.Address) {
+		key := strings.ToLower(addr.Address)
+		if !seen[key] {
+			seen[key] = true
+			addrs = append(addrs, format(addr))
+		}
+	}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if members, found := groups[entry]; found {
+			for _, member := range members {
+				addr, err := netmail.ParseAddress(member)
+				if err != nil {
+					return nil, errutil.NewNoPosf(errInvalidRecipient, member)
+				}
+				add(addr)
+			}
+			continue
+		}
+		list, err := netmail.ParseAddressList(entry)
+		if err != nil {
+			return nil, errutil.NewNoPosf(errInvalidRecipient, entry)
+		}
+		for _, addr := range list {
+			add(addr)
+		}
+	}
+	return addrs, nil
+}
+
+// format formats an address for mail headers. Display names are quoted and
+// encoded if necessary.
+func format(addr *netmail.Address) string {
+	if addr.Name == "" {
+		return addr.Address
+	}
+	return addr.String()
+}
+
+// envelope returns the bare addresses of recipients, as used by SMTP.
+func envelope(recipients []string) (addrs []string, err error) {
+	for _, recipient := range recipients {
+		addr, err := netmail.ParseAddress(recipient)
+		if err != nil {
+			return nil, errutil.NewNoPosf(errInvalidRecipient, recipient)
+		}
+		addrs = append(addrs, addr.Address)
+	}
+	return addrs, nil
+}
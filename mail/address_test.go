@@ -0,0 +1,48 @@
+package mail
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Tests ParseRecipients
+func TestParseRecipients(t *testing.T) {
+	groups := map[string][]string{
+		"team": {`"Alice" <alice@example.org>`, "bob@example.org"},
+	}
+	var testTable = []struct {
+		entries  []string
+		expected []string
+		valid    bool
+	}{
+		{[]string{"user@example.org"}, []string{"user@example.org"}, true},
+		{[]string{"User <user@example.org>"}, []string{`"User" <user@example.org>`}, true},
+		{[]string{"a@example.org, B <b@example.org>"}, []string{"a@example.org", `"B" <b@example.org>`}, true},
+		{[]string{"team", "carol@example.org"}, []string{`"Alice" <alice@example.org>`, "bob@example.org", "carol@example.org"}, true},
+		{[]string{"Bob@example.org", "team"}, []string{"Bob@example.org", `"Alice" <alice@example.org>`}, true},
+		{[]string{"Räksmörgås <r@example.org>"}, []string{"=?utf-8?q?R=C3=A4ksm=C3=B6rg=C3=A5s?= <r@example.org>"}, true},
+		{[]string{"nobody"}, nil, false},
+		{[]string{"user@example.org", "<broken"}, nil, false},
+	}
+	for _, test := range testTable {
+		output, err := ParseRecipients(test.entries, groups)
+		if (err == nil) != test.valid {
+			t.Errorf("%v: output valid `%v` != expected `%v`; %v", test.entries, err == nil, test.valid, err)
+			continue
+		}
+		if fmt.Sprint(output) != fmt.Sprint(test.expected) {
+			t.Errorf("output `%v` != expected `%v`", output, test.expected)
+		}
+	}
+}
+
+// Tests envelope
+func TestEnvelope(t *testing.T) {
+	output, err := envelope([]string{`"Alice" <alice@example.org>`, "bob@example.org"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "[alice@example.org bob@example.org]"; fmt.Sprint(output) != expected {
+		t.Errorf("output `%v` != expected `%v`", output, expected)
+	}
+}
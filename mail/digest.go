@@ -0,0 +1,74 @@
+package mail
+
+import (
+	htmltemplate "html/template"
+	"text/template"
+	"time"
+
+	"github.com/karlek/nyfiken/settings"
+	"github.com/mewkiz/pkg/errutil"
+)
+
+// Templates of digests, which combine the updates of several pages into one
+// mail.
+const (
+	DigestSubject = `[ nyfiken ] {{len .Updates}} updated page{{if gt (len .Updates) 1}}s{{end}}`
+
+	DigestText = `{{len .Updates}} page{{if gt (len .Updates) 1}}s have{{else}} has{{end}} been updated :)
+{{range .Updates}}
+{{.Title}} ({{.Time.Format "2006-01-02 15:04"}})
+{{.URL}}
+{{if .Diff}}{{.Diff}}{{end}}{{end}}`
+
+	DigestHTML = `<!DOCTYPE html>
+<html>
+<body>
+<p>{{len .Updates}} page{{if gt (len .Updates) 1}}s have{{else}} has{{end}} been updated :)</p>
+{{range .Updates}}<hr>
+<p><a href="{{.URL}}">{{.Title}}</a> ({{.Time.Format "2006-01-02 15:04"}})</p>
+{{if .Diff}}<pre style="border: 1px solid #ddd; padding: 4px; white-space: pre-wrap;">{{.Diff}}</pre>
+{{end}}{{end}}</body>
+</html>
+`
+)
+
+// digestTemplates formats digests.
+var digestTemplates = &Templates{
+	Subject: template.Must(template.New("subject").Parse(DigestSubject)),
+	Text:    template.Must(template.New("text").Parse(DigestText)),
+	HTML:    htmltemplate.Must(htmltemplate.New("html").Parse(DigestHTML)),
+}
+
+// A Digest is a list of updates which are mailed together.
+type Digest struct {
+	Time    time.Time // Time of the digest.
+	Updates []*Update // Updates since the previous digest, in order of detection.
+}
+
+// ComposeDigest returns the mail of a digest to the recipients.
+func ComposeDigest(to, cc []string, d *Digest) (msg *Message, err error) {
+	subject, text, html, err := digestTemplates.execute(d)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	msg = &Message{
+		From:    settings.Global.SenderMail.Address,
+		To:      to,
+		Cc:      cc,
+		Subject: subject,
+		Date:    d.Time,
+		ListID:  ListID("digest"),
+		Text:    text,
+		HTML:    html,
+	}
+	return msg, nil
+}
+
+// SendDigest sends the mail of a digest to the recipients.
+func SendDigest(to, cc []string, d *Digest) (err error) {
+	msg, err := ComposeDigest(to, cc, d)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	return send(msg)
+}
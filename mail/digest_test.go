@@ -0,0 +1,46 @@
+package mail
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Tests ComposeDigest
+func TestComposeDigest(t *testing.T) {
+	a, _ := url.Parse("http://example.org/a")
+	b, _ := url.Parse("http://example.org/b")
+	d := &Digest{
+		Time: time.Date(2014, 3, 1, 8, 0, 0, 0, time.UTC),
+		Updates: []*Update{
+			{URL: a, Title: "A", Time: time.Date(2014, 2, 28, 13, 0, 0, 0, time.UTC), Diff: "+ first\n"},
+			{URL: b, Title: "B <b>", Time: time.Date(2014, 2, 28, 14, 0, 0, 0, time.UTC), Diff: "- second\n"},
+		},
+	}
+	msg, err := ComposeDigest([]string{"user@example.org"}, nil, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "[ nyfiken ] 2 updated pages"; msg.Subject != expected {
+		t.Errorf("output `%v` != expected `%v`", msg.Subject, expected)
+	}
+	expected := "2 pages have been updated :)\n\nA (2014-02-28 13:00)\nhttp://example.org/a\n+ first\n\nB <b> (2014-02-28 14:00)\nhttp://example.org/b\n- second\n"
+	if msg.Text != expected {
+		t.Errorf("output `%v` != expected `%v`", msg.Text, expected)
+	}
+	for _, expected := range []string{`<a href="http://example.org/a">A</a>`, "B &lt;b&gt;", "- second"} {
+		if !strings.Contains(msg.HTML, expected) {
+			t.Errorf("html body `%v` doesn't contain `%v`", msg.HTML, expected)
+		}
+	}
+
+	d.Updates = d.Updates[:1]
+	msg, err = ComposeDigest([]string{"user@example.org"}, nil, d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "[ nyfiken ] 1 updated page"; msg.Subject != expected {
+		t.Errorf("output `%v` != expected `%v`", msg.Subject, expected)
+	}
+}
@@ -2,46 +2,61 @@
 package mail
 
 import (
-	"net/smtp"
-	"net/url"
-
 	"github.com/karlek/nyfiken/settings"
 	"github.com/mewkiz/pkg/errutil"
 )
 
-// Send sends a mail to a mail address with the contents of the checked page and
-// the URL to the checked page.
-func Send(pageUrl *url.URL, receivingMail string, body string) (err error) {
-	// Set up authentication information.
-	auth := smtp.PlainAuth(
-		"",
-		settings.Global.SenderMail.Address,
-		settings.Global.SenderMail.Password,
-		settings.Global.SenderMail.AuthServer,
-	)
+// Compose returns the notification mail of an update to the recipients,
+// formatted by the templates.
+func Compose(to, cc []string, u *Update, t *Templates) (msg *Message, err error) {
+	subject, text, html, err := t.Execute(u)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	msg = &Message{
+		From:    settings.Global.SenderMail.Address,
+		To:      to,
+		Cc:      cc,
+		Subject: subject,
+		Date:    u.Time,
+		ListID:  ListID(u.URL.Host),
+		Text:    text,
+		HTML:    html,
+	}
+	return msg, nil
+}
 
-	// Connect to the server, authenticate, set the sender and recipient,
-	// and send the email all in one step.
-	var msg = `From: ` + settings.Global.SenderMail.Address + `
-To: ` + receivingMail + `
-Subject: [ nyfiken ] ` + pageUrl.Host + `: update
-MIME-Version: 1.0
-Content-Transfer-Encoding: 8bit
-Content-Type: text/html; charset="UTF-8"
+// Send sends a mail to the recipients about an update of a checked page,
+// formatted by the templates.
+func Send(to, cc []string, u *Update, t *Templates) (err error) {
+	msg, err := Compose(to, cc, u, t)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	return send(msg)
+}
 
-` + `<a href="` + pageUrl.String() + `">` + pageUrl.String() + `</a> has been updated :) <hr>
-` + body + `</body><html>` + settings.Newline
+// send sends a message using the outgoing server or the sendmail binary of the
+// [mail] section.
+func send(msg *Message) (err error) {
+	buf, err := msg.Bytes()
+	if err != nil {
+		return errutil.Err(err)
+	}
 
-	err = smtp.SendMail(
-		settings.Global.SenderMail.OutServer, // Outgoing server.
-		auth, // Authorization information.
-		settings.Global.SenderMail.Address, // From what mail.
-		[]string{receivingMail},            // To which mail.
-		[]byte(msg),                        // Content to send.
-	)
+	rcpts, err := envelope(append(append([]string{}, msg.To...), msg.Cc...))
 	if err != nil {
 		return errutil.Err(err)
 	}
 
+	sender := &settings.Global.SenderMail
+	if sender.Sendmail != "" {
+		err = sendSendmail(sender, rcpts, buf)
+	} else {
+		err = sendSMTP(sender, rcpts, buf)
+	}
+	if err != nil {
+		return errutil.Err(err)
+	}
 	return nil
 }
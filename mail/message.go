@@ -0,0 +1,134 @@
+package mail
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/mewkiz/pkg/errutil"
+)
+
+// Message is a mail with a plain text and an HTML alternative of its body.
+type Message struct {
+	From      string    // Mail address of the sender.
+	To        []string  // Mail addresses of the recipients.
+	Cc        []string  // Mail addresses of the carbon copy recipients.
+	Subject   string    // Subject; encoded according to RFC 2047 if necessary.
+	Date      time.Time // Date of the message; the current time if zero.
+	MessageID string    // Message-ID header without angle brackets; generated if empty.
+	ListID    string    // List-Id header which mail clients may filter on; omitted if empty.
+	Text      string    // Plain text body.
+	HTML      string    // HTML body; omitted if empty.
+}
+
+// Bytes returns the message formatted according to RFC 5322, with a
+// multipart/alternative body if it has an HTML part.
+func (m *Message) Bytes() (buf []byte, err error) {
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	id := m.MessageID
+	if id == "" {
+		id, err = messageID(m.From)
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+	}
+
+	b := new(bytes.Buffer)
+	writeHeader(b, "From", m.From)
+	writeHeader(b, "To", strings.Join(m.To, ", "))
+	if len(m.Cc) > 0 {
+		writeHeader(b, "Cc", strings.Join(m.Cc, ", "))
+	}
+	writeHeader(b, "Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	writeHeader(b, "Date", date.Format(time.RFC1123Z))
+	writeHeader(b, "Message-ID", "<"+id+">")
+	if m.ListID != "" {
+		writeHeader(b, "List-Id", m.ListID)
+	}
+	writeHeader(b, "MIME-Version", "1.0")
+
+	if m.HTML == "" {
+		writeHeader(b, "Content-Type", `text/plain; charset="utf-8"`)
+		writeHeader(b, "Content-Transfer-Encoding", "quoted-printable")
+		b.WriteString("\r\n")
+		if err = writeQuoted(b, m.Text); err != nil {
+			return nil, errutil.Err(err)
+		}
+		return b.Bytes(), nil
+	}
+
+	w := multipart.NewWriter(b)
+	writeHeader(b, "Content-Type", "multipart/alternative; boundary="+w.Boundary())
+	b.WriteString("\r\n")
+	// The preferred alternative is the last one.
+	for _, part := range []struct{ typ, body string }{
+		{"text/plain", m.Text},
+		{"text/html", m.HTML},
+	} {
+		pw, err := w.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.typ + `; charset="utf-8"`},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+		if err = writeQuoted(pw, part.body); err != nil {
+			return nil, errutil.Err(err)
+		}
+	}
+	if err = w.Close(); err != nil {
+		return nil, errutil.Err(err)
+	}
+	return b.Bytes(), nil
+}
+
+// writeHeader writes a header field of a message. Line breaks are removed from
+// the value to prevent header injection.
+func writeHeader(w io.Writer, key, value string) {
+	value = strings.NewReplacer("\r", "", "\n", "").Replace(value)
+	fmt.Fprintf(w, "%s: %s\r\n", key, value)
+}
+
+// writeQuoted writes s to w using the quoted-printable encoding.
+func writeQuoted(w io.Writer, s string) (err error) {
+	qw := quotedprintable.NewWriter(w)
+	if _, err = io.WriteString(qw, s); err != nil {
+		return errutil.Err(err)
+	}
+	return qw.Close()
+}
+
+// messageID returns a unique message ID in the domain of the sender's mail
+// address.
+func messageID(from string) (id string, err error) {
+	domain := "nyfiken"
+	if pos := strings.LastIndex(from, "@"); pos != -1 {
+		domain = strings.TrimSuffix(from[pos+1:], ">")
+	}
+	buf := make([]byte, 8)
+	if _, err = rand.Read(buf); err != nil {
+		return "", errutil.Err(err)
+	}
+	return fmt.Sprintf("%d.%s@%s", time.Now().UnixNano(), hex.EncodeToString(buf), domain), nil
+}
+
+// ListID returns the List-Id header of notifications about a host, so that mail
+// clients may filter notifications per site.
+func ListID(host string) string {
+	if host == "" {
+		host = "local"
+	}
+	label := strings.NewReplacer(":", ".", "[", "", "]", "").Replace(host)
+	return fmt.Sprintf(`"nyfiken updates of %s" <%s.nyfiken>`, host, label)
+}
@@ -0,0 +1,192 @@
+package mail
+
+import (
+	"bytes"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/karlek/nyfiken/diff"
+)
+
+// Tests Message.Bytes
+func TestMessageBytes(t *testing.T) {
+	date := time.Date(2014, 3, 1, 12, 0, 0, 0, time.UTC)
+	m := &Message{
+		From:      "sender@example.com",
+		To:        []string{"a@example.org", "b@example.org"},
+		Cc:        []string{`"C" <c@example.org>`},
+		Subject:   "Uppdaterad: räksmörgås",
+		Date:      date,
+		MessageID: "1.2@example.com",
+		ListID:    ListID("example.org:8080"),
+		Text:      "plain ✓",
+		HTML:      "<p>html ✓</p>",
+	}
+	buf, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(msg.Header.Get("Subject"), "ä") {
+		t.Errorf("subject isn't encoded: %v", msg.Header.Get("Subject"))
+	}
+	headers := []struct {
+		key, output, expected string
+	}{
+		{"Subject", subject, m.Subject},
+		{"To", msg.Header.Get("To"), "a@example.org, b@example.org"},
+		{"Cc", msg.Header.Get("Cc"), `"C" <c@example.org>`},
+		{"Message-ID", msg.Header.Get("Message-ID"), "<1.2@example.com>"},
+		{"List-Id", msg.Header.Get("List-Id"), `"nyfiken updates of example.org:8080" <example.org.8080.nyfiken>`},
+	}
+	for _, h := range headers {
+		if h.output != h.expected {
+			t.Errorf("%s: output `%v` != expected `%v`", h.key, h.output, h.expected)
+		}
+	}
+	d, err := msg.Header.Date()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Equal(date) {
+		t.Errorf("Date: output `%v` != expected `%v`", d, date)
+	}
+
+	typ, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ != "multipart/alternative" {
+		t.Fatalf("Content-Type: output `%v` != expected `%v`", typ, "multipart/alternative")
+	}
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	golden := []struct {
+		typ, body string
+	}{
+		{`text/plain; charset="utf-8"`, m.Text},
+		{`text/html; charset="utf-8"`, m.HTML},
+	}
+	for _, g := range golden {
+		// The reader decodes quoted-printable parts.
+		part, err := r.NextPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if part.Header.Get("Content-Type") != g.typ {
+			t.Errorf("output `%v` != expected `%v`", part.Header.Get("Content-Type"), g.typ)
+		}
+		if string(body) != g.body {
+			t.Errorf("output `%v` != expected `%v`", string(body), g.body)
+		}
+	}
+}
+
+// Tests that header values can't inject headers.
+func TestMessageHeaderInjection(t *testing.T) {
+	m := &Message{
+		From:    "sender@example.com",
+		To:      []string{"a@example.org"},
+		Subject: "update\r\nBcc: evil@example.org",
+		Text:    "plain",
+	}
+	buf, err := m.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bcc := msg.Header.Get("Bcc"); bcc != "" {
+		t.Errorf("injected header: Bcc: %v", bcc)
+	}
+	if !strings.HasSuffix(msg.Header.Get("Message-ID"), "@example.com>") {
+		t.Errorf("invalid generated Message-ID: %v", msg.Header.Get("Message-ID"))
+	}
+}
+
+// Tests Templates.Execute
+func TestTemplates(t *testing.T) {
+	u, _ := url.Parse("http://example.org/news?a=1&b=2")
+	update := &Update{
+		URL:   u,
+		Host:  u.Host,
+		Title: "News <3",
+		Body:  "<p>selection</p>",
+		Diff:  "+ new line\n",
+	}
+
+	tmpl, err := ParseTemplates("", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, text, html, err := tmpl.Execute(update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "[ nyfiken ] example.org: update"; subject != expected {
+		t.Errorf("output `%v` != expected `%v`", subject, expected)
+	}
+	if expected := "http://example.org/news?a=1&b=2 has been updated :)\n\n+ new line\n"; text != expected {
+		t.Errorf("output `%v` != expected `%v`", text, expected)
+	}
+	for _, expected := range []string{`href="http://example.org/news?a=1&amp;b=2"`, "News &lt;3", "<p>selection</p>"} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("html body `%v` doesn't contain `%v`", html, expected)
+		}
+	}
+
+	// Changes are shown as highlighted hunks with a link to the full version.
+	update.Hunks = diff.Hunks(diff.Lines("a\nb\n", "a\n<c>\n"), 1)
+	update.Full = "http://example.org/news?a=1&b=2"
+	_, text, html, err = tmpl.Execute(update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "http://example.org/news?a=1&b=2 has been updated :)\n\n@@ -1,2 +1,2 @@\n  a\n- b\n+ <c>\n\nFull version: http://example.org/news?a=1&b=2\n"; text != expected {
+		t.Errorf("output `%v` != expected `%v`", text, expected)
+	}
+	for _, expected := range []string{`color: #22863a;">&#43; &lt;c&gt;</span>`, `color: #b31d28;">- b</span>`, `href="http://example.org/news?a=1&amp;b=2"`} {
+		if !strings.Contains(html, expected) {
+			t.Errorf("html body `%v` doesn't contain `%v`", html, expected)
+		}
+	}
+	if strings.Contains(html, "<p>selection</p>") {
+		t.Errorf("html body `%v` contains the selection instead of the diff", html)
+	}
+
+	// Subjects are a single line.
+	tmpl, err = ParseTemplates("{{.Title}}\n{{.Host}}", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, _, _, err = tmpl.Execute(update)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "News <3 example.org"; subject != expected {
+		t.Errorf("output `%v` != expected `%v`", subject, expected)
+	}
+
+	if _, err = ParseTemplates("{{.Title", "", ""); err == nil {
+		t.Errorf("expected error for invalid template")
+	}
+}
@@ -0,0 +1,42 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strings"
+
+	"github.com/karlek/nyfiken/settings"
+	"github.com/mewkiz/pkg/errutil"
+)
+
+// Error messages.
+var (
+	errSendmailFailure = "mail: %s: %v: %s"
+)
+
+// sendSendmail delivers a message to its recipients using the sendmail binary
+// of the sender.
+func sendSendmail(sender *settings.Sender, to []string, buf []byte) (err error) {
+	timeout := sender.Timeout
+	if timeout == 0 {
+		timeout = settings.DefaultMailTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// -i: don't treat a line with a single dot as the end of the message.
+	// -f: envelope sender.
+	args := append([]string{"-i", "-f", sender.Address, "--"}, to...)
+	cmd := exec.CommandContext(ctx, sender.Sendmail, args...)
+	// Sendmail expects local line endings.
+	cmd.Stdin = bytes.NewReader(bytes.Replace(buf, []byte("\r\n"), []byte("\n"), -1))
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err = cmd.Run()
+	if err != nil {
+		return errutil.NewNoPosf(errSendmailFailure, sender.Sendmail, err, strings.TrimSpace(out.String()))
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package mail
+
+import (
+	"crypto/tls"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/karlek/nyfiken/settings"
+	"github.com/mewkiz/pkg/errutil"
+)
+
+// Error messages.
+var (
+	errNoStartTLS       = "mail: %s doesn't support STARTTLS; use `security = tls` or `security = none`."
+	errNoAuth           = "mail: %s doesn't support authentication; use `auth = none`."
+	errInvalidSecurity  = "mail: invalid security: `%s`; valid values: starttls, tls and none."
+	errInvalidAuth      = "mail: invalid authentication mechanism: `%s`; valid values: plain, login, cram-md5 and none."
+	errUnencryptedLogin = "mail: unencrypted connection; refusing to send the password to %s."
+	errWrongHost        = "mail: wrong host name: %s; expected %s."
+	errLoginChallenge   = "mail: unexpected LOGIN challenge: `%s`."
+)
+
+// sendSMTP sends a message to its recipients through the outgoing server of
+// the sender.
+func sendSMTP(sender *settings.Sender, to []string, buf []byte) (err error) {
+	host, _, err := net.SplitHostPort(sender.OutServer)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	serverName := sender.AuthServer
+	if serverName == "" {
+		serverName = host
+	}
+	tlsConfig := &tls.Config{ServerName: serverName}
+
+	timeout := sender.Timeout
+	if timeout == 0 {
+		timeout = settings.DefaultMailTimeout
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	switch sender.Security {
+	case settings.SecurityTLS:
+		conn, err = tls.DialWithDialer(dialer, "tcp", sender.OutServer, tlsConfig)
+	case settings.SecurityStartTLS, settings.SecurityNone:
+		conn, err = dialer.Dial("tcp", sender.OutServer)
+	default:
+		return errutil.NewNoPosf(errInvalidSecurity, sender.Security)
+	}
+	if err != nil {
+		return errutil.Err(err)
+	}
+	// The timeout covers the whole session.
+	err = conn.SetDeadline(time.Now().Add(timeout))
+	if err != nil {
+		conn.Close()
+		return errutil.Err(err)
+	}
+
+	c, err := smtp.NewClient(conn, serverName)
+	if err != nil {
+		conn.Close()
+		return errutil.Err(err)
+	}
+	defer c.Close()
+
+	if sender.Helo != "" {
+		if err = c.Hello(sender.Helo); err != nil {
+			return errutil.Err(err)
+		}
+	}
+	if sender.Security == settings.SecurityStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return errutil.NewNoPosf(errNoStartTLS, sender.OutServer)
+		}
+		if err = c.StartTLS(tlsConfig); err != nil {
+			return errutil.Err(err)
+		}
+	}
+
+	auth, err := smtpAuth(sender, serverName)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errutil.NewNoPosf(errNoAuth, sender.OutServer)
+		}
+		if err = c.Auth(auth); err != nil {
+			return errutil.Err(err)
+		}
+	}
+
+	if err = c.Mail(sender.Address); err != nil {
+		return errutil.Err(err)
+	}
+	for _, addr := range to {
+		if err = c.Rcpt(addr); err != nil {
+			return errutil.Err(err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return errutil.Err(err)
+	}
+	if _, err = w.Write(buf); err != nil {
+		return errutil.Err(err)
+	}
+	if err = w.Close(); err != nil {
+		return errutil.Err(err)
+	}
+	return c.Quit()
+}
+
+// smtpAuth returns the authentication of the sender using its authentication
+// mechanism, or nil if it doesn't authenticate.
+func smtpAuth(sender *settings.Sender, serverName string) (auth smtp.Auth, err error) {
+	switch sender.Auth {
+	case settings.MailAuthPlain, "":
+		return smtp.PlainAuth("", sender.Address, sender.Password, serverName), nil
+	case settings.MailAuthLogin:
+		return &loginAuth{user: sender.Address, password: sender.Password, host: serverName}, nil
+	case settings.MailAuthCRAMMD5:
+		return smtp.CRAMMD5Auth(sender.Address, sender.Password), nil
+	case settings.MailAuthNone:
+		return nil, nil
+	}
+	return nil, errutil.NewNoPosf(errInvalidAuth, sender.Auth)
+}
+
+// loginAuth implements the LOGIN authentication mechanism, which is not
+// standardized but still required by some servers.
+type loginAuth struct {
+	user     string
+	password string
+	host     string
+}
+
+// Start begins the authentication with the server. Like PLAIN, the credentials
+// are only sent over encrypted connections, or to localhost.
+func (a *loginAuth) Start(server *smtp.ServerInfo) (proto string, toServer []byte, err error) {
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errutil.NewNoPosf(errUnencryptedLogin, server.Name)
+	}
+	if server.Name != a.host {
+		return "", nil, errutil.NewNoPosf(errWrongHost, server.Name, a.host)
+	}
+	return "LOGIN", nil, nil
+}
+
+// Next answers the user name and password challenges of the server.
+func (a *loginAuth) Next(fromServer []byte, more bool) (toServer []byte, err error) {
+	if !more {
+		return nil, nil
+	}
+	challenge := strings.ToLower(string(fromServer))
+	switch {
+	case strings.HasPrefix(challenge, "user"):
+		return []byte(a.user), nil
+	case strings.HasPrefix(challenge, "pass"):
+		return []byte(a.password), nil
+	}
+	return nil, errutil.NewNoPosf(errLoginChallenge, fromServer)
+}
+
+// isLocalhost reports whether the host name refers to the local machine.
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
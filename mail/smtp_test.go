@@ -0,0 +1,203 @@
+package mail
+
+import (
+	"bufio"
+	"encoding/base64"
+	"io/ioutil"
+	"net"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/karlek/nyfiken/settings"
+)
+
+// fakeSMTP starts an SMTP server which accepts one session, advertising the
+// extensions. The received lines are sent on the returned channel when the
+// session ends; AUTH LOGIN credentials are decoded.
+func fakeSMTP(t *testing.T, exts ...string) (addr string, session <-chan []string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := make(chan []string, 1)
+	go func() {
+		defer l.Close()
+		var lines []string
+		defer func() { ch <- lines }()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		readLine := func() (string, bool) {
+			line, err := r.ReadString('\n')
+			line = strings.TrimRight(line, "\r\n")
+			lines = append(lines, line)
+			return line, err == nil
+		}
+		decode := func() {
+			line, _ := readLine()
+			buf, _ := base64.StdEncoding.DecodeString(line)
+			lines[len(lines)-1] = string(buf)
+		}
+
+		reply("220 localhost ESMTP")
+		for {
+			line, ok := readLine()
+			if !ok {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line + " x")[0]); cmd {
+			case "EHLO":
+				reply("250-localhost")
+				for _, ext := range exts {
+					reply("250-" + ext)
+				}
+				reply("250 8BITMIME")
+			case "AUTH":
+				reply("334 " + base64.StdEncoding.EncodeToString([]byte("Username:")))
+				decode()
+				reply("334 " + base64.StdEncoding.EncodeToString([]byte("Password:")))
+				decode()
+				reply("235 ok")
+			case "DATA":
+				reply("354 go ahead")
+				for {
+					line, ok := readLine()
+					if !ok || line == "." {
+						break
+					}
+				}
+				reply("250 queued")
+			case "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return l.Addr().String(), ch
+}
+
+// contains reports whether the lines contain line.
+func contains(lines []string, line string) bool {
+	for _, l := range lines {
+		if l == line {
+			return true
+		}
+	}
+	return false
+}
+
+// Tests sendSMTP
+func TestSendSMTP(t *testing.T) {
+	msg := []byte("Subject: test\r\n\r\nbody\r\n")
+
+	// LOGIN authentication on an unencrypted connection to localhost.
+	addr, session := fakeSMTP(t, "AUTH LOGIN")
+	sender := &settings.Sender{
+		Address:    "sender@example.com",
+		Password:   "secret",
+		AuthServer: "127.0.0.1",
+		OutServer:  addr,
+		Security:   settings.SecurityNone,
+		Auth:       settings.MailAuthLogin,
+		Helo:       "nyfiken.example.com",
+	}
+	err := sendSMTP(sender, []string{"a@example.org", "b@example.org"}, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := <-session
+	for _, expected := range []string{
+		"EHLO nyfiken.example.com",
+		"AUTH LOGIN",
+		"sender@example.com",
+		"secret",
+		"MAIL FROM:<sender@example.com> BODY=8BITMIME",
+		"RCPT TO:<a@example.org>",
+		"RCPT TO:<b@example.org>",
+		"body",
+	} {
+		if !contains(lines, expected) {
+			t.Errorf("session `%v` doesn't contain `%v`", lines, expected)
+		}
+	}
+
+	// No authentication for local relays.
+	addr, session = fakeSMTP(t)
+	sender = &settings.Sender{Address: "sender@example.com", OutServer: addr, Security: settings.SecurityNone, Auth: settings.MailAuthNone}
+	if err = sendSMTP(sender, []string{"a@example.org"}, msg); err != nil {
+		t.Fatal(err)
+	}
+	if lines = <-session; !contains(lines, "RCPT TO:<a@example.org>") {
+		t.Errorf("session `%v` doesn't contain `%v`", lines, "RCPT TO:<a@example.org>")
+	}
+
+	// STARTTLS is required if requested.
+	addr, session = fakeSMTP(t)
+	sender = &settings.Sender{Address: "sender@example.com", OutServer: addr, Security: settings.SecurityStartTLS, Auth: settings.MailAuthNone}
+	if err = sendSMTP(sender, []string{"a@example.org"}, msg); err == nil {
+		t.Errorf("expected error for server without STARTTLS")
+	}
+	<-session
+
+	// Authentication is required if requested.
+	addr, session = fakeSMTP(t)
+	sender = &settings.Sender{Address: "sender@example.com", AuthServer: "127.0.0.1", OutServer: addr, Security: settings.SecurityNone, Auth: settings.MailAuthPlain}
+	if err = sendSMTP(sender, []string{"a@example.org"}, msg); err == nil {
+		t.Errorf("expected error for server without authentication")
+	}
+	<-session
+}
+
+// Tests that LOGIN credentials aren't sent unencrypted to remote hosts.
+func TestLoginAuthUnencrypted(t *testing.T) {
+	a := &loginAuth{user: "user", password: "secret", host: "mail.example.org"}
+	if _, _, err := a.Start(&smtp.ServerInfo{Name: "mail.example.org"}); err == nil {
+		t.Errorf("expected error for unencrypted connection")
+	}
+	if proto, _, err := a.Start(&smtp.ServerInfo{Name: "mail.example.org", TLS: true}); err != nil || proto != "LOGIN" {
+		t.Errorf("output `%v, %v` != expected `LOGIN, <nil>`", proto, err)
+	}
+}
+
+// Tests sendSendmail
+func TestSendSendmail(t *testing.T) {
+	dir, err := ioutil.TempDir("", "sendmail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "out")
+	sendmail := filepath.Join(dir, "sendmail")
+	script := "#!/bin/sh\necho \"$@\" > " + out + "\ncat >> " + out + "\n"
+	if err = ioutil.WriteFile(sendmail, []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	sender := &settings.Sender{Address: "sender@example.com", Sendmail: sendmail}
+	err = sendSendmail(sender, []string{"a@example.org"}, []byte("Subject: test\r\n\r\nbody\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := ioutil.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "-i -f sender@example.com -- a@example.org\nSubject: test\n\nbody\n"; string(buf) != expected {
+		t.Errorf("output `%v` != expected `%v`", string(buf), expected)
+	}
+
+	sender.Sendmail = filepath.Join(dir, "missing")
+	if err = sendSendmail(sender, []string{"a@example.org"}, nil); err == nil {
+		t.Errorf("expected error for missing sendmail binary")
+	}
+}
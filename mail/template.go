@@ -0,0 +1,128 @@
+package mail
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/karlek/nyfiken/diff"
+	"github.com/mewkiz/pkg/errutil"
+)
+
+// Default templates of notification mails.
+const (
+	DefaultSubject = `[ nyfiken ] {{.Host}}: update`
+
+	DefaultText = `{{.URL}} has been updated :)
+{{if .Hunks}}{{range .Hunks}}
+{{.Header}}
+{{range .Lines}}{{.}}
+{{end}}{{end}}{{if .Truncated}}...
+{{end}}{{if .Full}}
+Full version: {{.Full}}
+{{end}}{{else if .Diff}}
+{{.Diff}}{{end}}`
+
+	DefaultHTML = `<!DOCTYPE html>
+<html>
+<body>
+<p><a href="{{.URL}}">{{.Title}}</a> has been updated :)</p>
+<hr>
+{{if .Hunks}}{{range .Hunks}}<pre style="border: 1px solid #ddd; padding: 4px; white-space: pre-wrap;">
+<span style="color: #888;">{{.Header}}</span>
+{{range .Lines}}{{if .IsInsert}}<span style="background-color: #e6ffed; color: #22863a;">{{.}}</span>
+{{else if .IsDelete}}<span style="background-color: #ffeef0; color: #b31d28;">{{.}}</span>
+{{else}}{{.}}
+{{end}}{{end}}</pre>
+{{end}}{{if .Truncated}}<p>...</p>
+{{end}}{{if .Full}}<p><a href="{{.Full}}">Full version</a></p>
+{{end}}{{else}}{{.Body}}
+{{end}}</body>
+</html>
+`
+)
+
+// Update describes an updated page to the templates of notification mails.
+type Update struct {
+	URL      *url.URL          // URL of the updated page.
+	Host     string            // Host of the updated page, or its URL if it's local.
+	Title    string            // Title of the updated page.
+	Time     time.Time         // Time of the check which detected the update.
+	Score    float64           // Distance between the previous and the current check.
+	Body     htmltemplate.HTML // Selection of the updated page.
+	Diff     string            // Excerpt of the changed lines.
+	Snapshot string            // Identifier of the current check.
+
+	Hunks     []diff.Hunk      // Changes against the previous check, with context lines.
+	Truncated bool             // Hunks has been truncated.
+	Full      htmltemplate.URL // URL of the full version of the page, if it can be linked to.
+}
+
+// Templates formats notification mails.
+type Templates struct {
+	Subject *template.Template     // Template of the subject.
+	Text    *template.Template     // Template of the plain text body.
+	HTML    *htmltemplate.Template // Template of the HTML body.
+}
+
+// ParseTemplates parses the templates of notification mails. Empty templates
+// are replaced by the default templates.
+func ParseTemplates(subject, text, html string) (t *Templates, err error) {
+	if subject == "" {
+		subject = DefaultSubject
+	}
+	if text == "" {
+		text = DefaultText
+	}
+	if html == "" {
+		html = DefaultHTML
+	}
+
+	t = new(Templates)
+	t.Subject, err = template.New("subject").Parse(subject)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	t.Text, err = template.New("text").Parse(text)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	t.HTML, err = htmltemplate.New("html").Parse(html)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	return t, nil
+}
+
+// Execute formats the subject and bodies of the notification mail of an
+// update.
+func (t *Templates) Execute(u *Update) (subject, text, html string, err error) {
+	return t.execute(u)
+}
+
+// execute formats the subject and bodies of a mail using data.
+func (t *Templates) execute(data interface{}) (subject, text, html string, err error) {
+	buf := new(bytes.Buffer)
+	if err = t.Subject.Execute(buf, data); err != nil {
+		return "", "", "", errutil.Err(err)
+	}
+	// Subjects are a single line.
+	subject = strings.Join(strings.Fields(buf.String()), " ")
+
+	buf.Reset()
+	if err = t.Text.Execute(buf, data); err != nil {
+		return "", "", "", errutil.Err(err)
+	}
+	text = buf.String()
+
+	buf.Reset()
+	if err = t.HTML.Execute(buf, data); err != nil {
+		return "", "", "", errutil.Err(err)
+	}
+	html = buf.String()
+
+	return subject, text, html, nil
+}
@@ -0,0 +1,202 @@
+package notify
+
+import (
+	"context"
+	"html"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/karlek/nyfiken/settings"
+	"github.com/mewkiz/pkg/errutil"
+)
+
+// TypeDesktop shows updates as desktop notifications over D-Bus.
+const TypeDesktop = "desktop"
+
+// Desktop notifier field names.
+const (
+	fieldDesktopIcon   = "icon"
+	fieldDesktopExpire = "expire"
+)
+
+// Desktop notifications service of the freedesktop.org specification.
+const (
+	desktopDest  = "org.freedesktop.Notifications"
+	desktopPath  = dbus.ObjectPath("/org/freedesktop/Notifications")
+	desktopIface = "org.freedesktop.Notifications"
+)
+
+// Key of the action which opens the updated page.
+const actionOpen = "open"
+
+// Maximum number of changed lines shown in desktop notifications.
+const maxDesktopLines = 5
+
+func init() {
+	Register(TypeDesktop, []string{fieldDesktopIcon, fieldDesktopExpire}, newDesktop)
+}
+
+var (
+	// busMu protects bus and actions.
+	busMu sync.Mutex
+
+	// bus is the connection to the session bus, shared by all desktop
+	// notifiers.
+	bus *dbus.Conn
+
+	// actions maps the IDs of shown notifications to the URLs they open.
+	actions = make(map[uint32]string)
+)
+
+// Desktop is a notifier which shows updates as desktop notifications, with an
+// action which opens the updated page in the browser and marks it read.
+type Desktop struct {
+	name   string
+	Icon   string        // Icon of the notifications.
+	Expire time.Duration // Duration until notifications expire; 0 means never and -1 the default of the server.
+}
+
+// newDesktop creates a desktop notifier from its configuration.
+func newDesktop(conf *settings.Notifier) (Notifier, error) {
+	d := &Desktop{name: conf.Name, Icon: conf.Fields[fieldDesktopIcon], Expire: -1}
+	if s, found := conf.Fields[fieldDesktopExpire]; found {
+		expire, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+		d.Expire = expire
+	}
+	return d, nil
+}
+
+// Name returns the name of the notifier.
+func (d *Desktop) Name() string {
+	return d.name
+}
+
+// Notify shows the update as a desktop notification.
+func (d *Desktop) Notify(ctx context.Context, e *Event) (err error) {
+	conn, err := session()
+	if err != nil {
+		return errutil.Err(err)
+	}
+
+	expire := int32(-1)
+	if d.Expire >= 0 {
+		expire = int32(d.Expire / time.Millisecond)
+	}
+	var id uint32
+	err = conn.Object(desktopDest, desktopPath).CallWithContext(ctx, desktopIface+".Notify", 0,
+		"nyfiken",                    // Application name.
+		uint32(0),                    // ID of the notification to replace.
+		d.Icon,                       // Icon.
+		e.Title,                      // Summary.
+		desktopBody(e),               // Body.
+		[]string{actionOpen, "Open"}, // Actions.
+		map[string]dbus.Variant{},    // Hints.
+		expire,                       // Expiration timeout in milliseconds.
+	).Store(&id)
+	if err != nil {
+		return errutil.Err(err)
+	}
+
+	busMu.Lock()
+	actions[id] = e.URL.String()
+	busMu.Unlock()
+	return nil
+}
+
+// desktopBody returns the body of the desktop notification of an update: the
+// URL and the first changed lines. The body may contain markup, so it is
+// escaped.
+func desktopBody(e *Event) string {
+	lines := []string{e.URL.String()}
+	for _, line := range strings.Split(strings.TrimSuffix(e.Diff, "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		if len(lines) > maxDesktopLines {
+			lines = append(lines, "...")
+			break
+		}
+		lines = append(lines, line)
+	}
+	return html.EscapeString(strings.Join(lines, "\n"))
+}
+
+// session returns the connection to the session bus, connecting and listening
+// for actions if necessary.
+func session() (conn *dbus.Conn, err error) {
+	busMu.Lock()
+	defer busMu.Unlock()
+	if bus != nil {
+		return bus, nil
+	}
+
+	conn, err = dbus.ConnectSessionBus()
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	for _, member := range []string{"ActionInvoked", "NotificationClosed"} {
+		err = conn.AddMatchSignal(dbus.WithMatchInterface(desktopIface), dbus.WithMatchMember(member))
+		if err != nil {
+			conn.Close()
+			return nil, errutil.Err(err)
+		}
+	}
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	go listen(signals)
+
+	bus = conn
+	return bus, nil
+}
+
+// listen handles actions invoked on notifications until the connection to the
+// session bus is closed.
+func listen(signals <-chan *dbus.Signal) {
+	for sig := range signals {
+		if len(sig.Body) == 0 {
+			continue
+		}
+		id, ok := sig.Body[0].(uint32)
+		if !ok {
+			continue
+		}
+
+		busMu.Lock()
+		u, found := actions[id]
+		if sig.Name == desktopIface+".NotificationClosed" {
+			delete(actions, id)
+		}
+		busMu.Unlock()
+
+		if !found || sig.Name != desktopIface+".ActionInvoked" || len(sig.Body) < 2 {
+			continue
+		}
+		if key, _ := sig.Body[1].(string); key == actionOpen {
+			err := open(u)
+			if err != nil {
+				log.Println(errutil.Err(err))
+			}
+		}
+	}
+}
+
+// open opens the URL in the browser and marks it read. It's called by listen
+// concurrently with checks, so the list of updates is only changed through the
+// locked settings.MarkRead.
+func open(u string) (err error) {
+	if settings.Global.Browser == "" {
+		return errutil.NewNoPosf("notify: no browser path set in: %s", settings.ConfigPath)
+	}
+	err = exec.Command(settings.Global.Browser, u).Start()
+	if err != nil {
+		return errutil.Err(err)
+	}
+	return settings.MarkRead(u)
+}
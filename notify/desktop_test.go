@@ -0,0 +1,39 @@
+package notify
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/karlek/nyfiken/settings"
+)
+
+// Tests newDesktop and desktopBody
+func TestDesktop(t *testing.T) {
+	n, err := New(&settings.Notifier{Name: "desk", Type: TypeDesktop, Fields: map[string]string{"expire": "10s"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := n.(*Desktop); d.Expire != 10*time.Second {
+		t.Errorf("output `%v` != expected `%v`", d.Expire, 10*time.Second)
+	}
+	if _, err = New(&settings.Notifier{Name: "desk", Type: TypeDesktop, Fields: map[string]string{"expire": "soon"}}); err == nil {
+		t.Errorf("expected error for invalid expire")
+	}
+
+	u, _ := url.Parse("http://example.org/?a=1&b=2")
+	var testTable = []struct {
+		diff     string
+		expected string
+	}{
+		{"", "http://example.org/?a=1&amp;b=2"},
+		{"- <b>old</b>\n+ new\n", "http://example.org/?a=1&amp;b=2\n- &lt;b&gt;old&lt;/b&gt;\n+ new"},
+		{"+ 1\n+ 2\n+ 3\n+ 4\n+ 5\n+ 6\n", "http://example.org/?a=1&amp;b=2\n+ 1\n+ 2\n+ 3\n+ 4\n+ 5\n..."},
+	}
+	for _, test := range testTable {
+		output := desktopBody(&Event{URL: u, Diff: test.diff})
+		if output != test.expected {
+			t.Errorf("output `%v` != expected `%v`", output, test.expected)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package notify
+
+import (
+	"crypto/sha1"
+	"encoding/gob"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/karlek/nyfiken/mail"
+	"github.com/karlek/nyfiken/settings"
+	"github.com/mewkiz/pkg/errutil"
+	"github.com/mewkiz/pkg/osutil"
+)
+
+// Prefix of schedules of daily digests, e.g. `daily 08:00`.
+const scheduleDaily = "daily"
+
+// Schedule which disables digests, e.g. of a notifier when the [mail] section
+// has a digest schedule.
+const scheduleNone = "none"
+
+// Error messages.
+var (
+	errInvalidSchedule = "notify: invalid digest schedule: `%s`; correct syntax -> `1h` or `daily 08:00`."
+)
+
+// A Schedule decides when digests are sent; either at an interval after the
+// first queued update, or daily at a time of day.
+type Schedule struct {
+	Interval time.Duration // Interval after the first queued update; 0 if daily.
+	Daily    time.Duration // Time of day of daily digests, as a duration after midnight.
+}
+
+// ParseSchedule parses a digest schedule, e.g. `1h` or `daily 08:00`. An empty
+// schedule or `none` disables digests and returns nil.
+func ParseSchedule(s string) (sched *Schedule, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == scheduleNone {
+		return nil, nil
+	}
+	if strings.HasPrefix(s, scheduleDaily) {
+		t, err := time.Parse("15:04", strings.TrimSpace(strings.TrimPrefix(s, scheduleDaily)))
+		if err != nil {
+			return nil, errutil.NewNoPosf(errInvalidSchedule, s)
+		}
+		return &Schedule{Daily: time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute}, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return nil, errutil.NewNoPosf(errInvalidSchedule, s)
+	}
+	return &Schedule{Interval: d}, nil
+}
+
+// Next returns the time of the digest of an update queued at t.
+func (sched *Schedule) Next(t time.Time) time.Time {
+	if sched.Interval != 0 {
+		return t.Add(sched.Interval)
+	}
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	next := midnight.Add(sched.Daily)
+	if !next.After(t) {
+		next = midnight.AddDate(0, 0, 1).Add(sched.Daily)
+	}
+	return next
+}
+
+// A queue is the persisted list of updates of a digest.
+type queue struct {
+	To      []string  // Mail addresses to send the digest to.
+	Cc      []string  // Mail addresses to send copies of the digest to.
+	Next    time.Time // Time to send the digest.
+	Updates []queued  // Queued updates.
+}
+
+// A queued is a queued update of a digest.
+type queued struct {
+	URL   string
+	Title string
+	Time  time.Time
+	Score float64
+	Diff  string
+}
+
+// Extension of digest queues.
+const queueExt = ".gob"
+
+// Extension of queues whose digests are being sent.
+const sendingExt = ".sending"
+
+var (
+	// digestMu protects the queues of digests from concurrent checks. It's not
+	// held while digests are sent, so that checks aren't held up by slow mail
+	// servers.
+	digestMu sync.Mutex
+
+	// sendMu serializes SendDigests.
+	sendMu sync.Mutex
+)
+
+// queuePath returns the path of the queue of a notifier's digests. Queues are
+// named by the hash of their recipients, since a list of recipients may exceed
+// the maximum length of file names.
+func queuePath(name string, to []string) string {
+	sorted := append([]string(nil), to...)
+	sort.Strings(sorted)
+	sum := sha1.Sum([]byte(strings.Join(sorted, ",")))
+	return settings.DigestRoot + url.QueryEscape(name) + "-" + hex.EncodeToString(sum[:]) + queueExt
+}
+
+// enqueue adds an update to the digest of a notifier, which is sent according
+// to the schedule.
+func enqueue(name string, to, cc []string, sched *Schedule, e *Event) (err error) {
+	digestMu.Lock()
+	defer digestMu.Unlock()
+
+	path := queuePath(name, to)
+	q := &queue{To: to, Cc: cc, Next: sched.Next(time.Now())}
+	if osutil.Exists(path) {
+		q, err = loadQueue(path)
+		if err != nil {
+			return errutil.Err(err)
+		}
+	}
+	q.Updates = append(q.Updates, queued{
+		URL:   e.URL.String(),
+		Title: e.Title,
+		Time:  e.Time,
+		Score: e.Score,
+		Diff:  e.Diff,
+	})
+	return saveQueue(path, q)
+}
+
+// SendDigests sends the digests which are due at now. The updates of sent
+// digests are considered read. Due queues are moved aside before their digests
+// are sent, so that updates queued meanwhile are added to new queues; the
+// queues of digests which fail to be sent are restored.
+func SendDigests(now time.Time) (err error) {
+	sendMu.Lock()
+	defer sendMu.Unlock()
+
+	paths, err := takeDue(now)
+	if err != nil {
+		return errutil.Err(err)
+	}
+
+	// Send all digests, even if one of them fails.
+	var sendErr error
+	for _, path := range paths {
+		err = sendDigest(path, now)
+		if err != nil {
+			if restoreErr := restoreQueue(path); restoreErr != nil {
+				log.Println(errutil.Err(restoreErr))
+			}
+			if sendErr != nil {
+				log.Println(errutil.Err(sendErr))
+			}
+			sendErr = err
+		}
+	}
+	if sendErr != nil {
+		return errutil.Err(sendErr)
+	}
+	return nil
+}
+
+// takeDue moves the queues whose digests are due at now aside, and returns
+// their new paths. Queues which were moved aside but never sent, e.g. because
+// nyfikend was stopped, are returned as well.
+func takeDue(now time.Time) (paths []string, err error) {
+	digestMu.Lock()
+	defer digestMu.Unlock()
+
+	fis, err := ioutil.ReadDir(settings.DigestRoot)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	for _, fi := range fis {
+		path := settings.DigestRoot + fi.Name()
+		if strings.HasSuffix(fi.Name(), sendingExt) {
+			paths = append(paths, path)
+			continue
+		}
+		// Skip queues which are being written.
+		if !strings.HasSuffix(fi.Name(), queueExt) {
+			continue
+		}
+		q, err := loadQueue(path)
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+		if now.Before(q.Next) {
+			continue
+		}
+		sending := path + "." + strconv.FormatInt(now.UnixNano(), 10) + sendingExt
+		err = os.Rename(path, sending)
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+		paths = append(paths, sending)
+	}
+	return paths, nil
+}
+
+// restoreQueue moves a queue whose digest failed to be sent back, so that it's
+// sent again. Updates queued meanwhile are appended to it.
+func restoreQueue(sending string) (err error) {
+	digestMu.Lock()
+	defer digestMu.Unlock()
+
+	q, err := loadQueue(sending)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	// Strip the extension and the time it was moved aside.
+	path := strings.TrimSuffix(sending, sendingExt)
+	path = path[:strings.LastIndex(path, ".")]
+	if osutil.Exists(path) {
+		cur, err := loadQueue(path)
+		if err != nil {
+			return errutil.Err(err)
+		}
+		q.Updates = append(q.Updates, cur.Updates...)
+	}
+	err = saveQueue(path, q)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	return os.Remove(sending)
+}
+
+// sendDigest sends the digest of a queue which has been moved aside, and
+// removes the queue.
+func sendDigest(path string, now time.Time) (err error) {
+	q, err := loadQueue(path)
+	if err != nil {
+		return errutil.Err(err)
+	}
+
+	d := &mail.Digest{Time: now}
+	for _, u := range q.Updates {
+		pageURL, err := url.Parse(u.URL)
+		if err != nil {
+			return errutil.Err(err)
+		}
+		d.Updates = append(d.Updates, &mail.Update{
+			URL:   pageURL,
+			Host:  pageURL.Host,
+			Title: u.Title,
+			Time:  u.Time,
+			Score: u.Score,
+			Diff:  u.Diff,
+		})
+	}
+	if len(d.Updates) > 0 {
+		err = mail.SendDigest(q.To, q.Cc, d)
+		if err != nil {
+			return errutil.Err(err)
+		}
+	}
+	err = os.Remove(path)
+	if err != nil {
+		return errutil.Err(err)
+	}
+
+	var urls []string
+	for _, u := range q.Updates {
+		urls = append(urls, u.URL)
+	}
+	return settings.MarkRead(urls...)
+}
+
+// loadQueue loads the queue of a digest.
+func loadQueue(path string) (q *queue, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	defer f.Close()
+
+	q = new(queue)
+	err = gob.NewDecoder(f).Decode(q)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	return q, nil
+}
+
+// saveQueue saves the queue of a digest. It's written to a temporary file
+// first, so that a crash never leaves a partially written queue.
+func saveQueue(path string, q *queue) (err error) {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, settings.Global.FilePerms)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	err = gob.NewEncoder(f).Encode(q)
+	if err != nil {
+		f.Close()
+		return errutil.Err(err)
+	}
+	err = f.Close()
+	if err != nil {
+		return errutil.Err(err)
+	}
+	err = os.Rename(tmp, path)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	return nil
+}
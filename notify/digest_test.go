@@ -0,0 +1,171 @@
+package notify
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/karlek/nyfiken/settings"
+)
+
+// Tests ParseSchedule and Schedule.Next
+func TestSchedule(t *testing.T) {
+	now := time.Date(2014, 3, 1, 12, 30, 0, 0, time.UTC)
+	var testTable = []struct {
+		s        string
+		expected time.Time
+	}{
+		{"1h", now.Add(time.Hour)},
+		{"90m", now.Add(90 * time.Minute)},
+		{"daily 08:00", time.Date(2014, 3, 2, 8, 0, 0, 0, time.UTC)},
+		{"daily 18:15", time.Date(2014, 3, 1, 18, 15, 0, 0, time.UTC)},
+		{"daily 12:30", time.Date(2014, 3, 2, 12, 30, 0, 0, time.UTC)},
+	}
+	for _, test := range testTable {
+		sched, err := ParseSchedule(test.s)
+		if err != nil {
+			t.Errorf("%s: %v", test.s, err)
+			continue
+		}
+		output := sched.Next(now)
+		if !output.Equal(test.expected) {
+			t.Errorf("output `%v` != expected `%v`", output, test.expected)
+		}
+	}
+
+	for _, s := range []string{"", "none"} {
+		sched, err := ParseSchedule(s)
+		if err != nil || sched != nil {
+			t.Errorf("%s: expected no schedule; got %v, %v", s, sched, err)
+		}
+	}
+	for _, s := range []string{"daily", "daily 25:00", "weekly", "-1h"} {
+		if _, err := ParseSchedule(s); err == nil {
+			t.Errorf("%s: expected error for invalid schedule", s)
+		}
+	}
+}
+
+// Tests that queued updates are persisted until their digest is due.
+func TestEnqueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "digests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	digestRoot := settings.DigestRoot
+	settings.DigestRoot = dir + "/"
+	defer func() { settings.DigestRoot = digestRoot }()
+
+	sched := &Schedule{Interval: time.Hour}
+	for _, rawurl := range []string{"http://example.org/a", "http://example.org/b"} {
+		u, _ := url.Parse(rawurl)
+		err = enqueue("team", []string{"team@example.org"}, nil, sched, &Event{URL: u, Title: rawurl, Diff: "+ new\n"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := queuePath("team", []string{"team@example.org"})
+	q, err := loadQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.To) != 1 || q.To[0] != "team@example.org" || len(q.Updates) != 2 || q.Updates[1].URL != "http://example.org/b" {
+		t.Errorf("unexpected queue: %v", q)
+	}
+	if d := time.Until(q.Next); d <= 0 || d > time.Hour {
+		t.Errorf("invalid time of digest: %v", q.Next)
+	}
+
+	// Digests which aren't due are kept.
+	err = SendDigests(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(path); err != nil {
+		t.Errorf("queue removed before its digest was due: %v", err)
+	}
+}
+
+// Tests that the names of queues are short, and independent of the order of
+// recipients.
+func TestQueuePath(t *testing.T) {
+	var to []string
+	for i := 0; i < 100; i++ {
+		to = append(to, fmt.Sprintf("user%d@example.org", i))
+	}
+	name := filepath.Base(queuePath("team", to))
+	if len(name) > 255 {
+		t.Errorf("queue name too long: %d bytes", len(name))
+	}
+	a := queuePath("team", []string{"a@example.org", "b@example.org"})
+	b := queuePath("team", []string{"b@example.org", "a@example.org"})
+	if a != b {
+		t.Errorf("output `%v` != expected `%v`", b, a)
+	}
+	if c := queuePath("team", []string{"a@example.org"}); c == a {
+		t.Errorf("same queue for different recipients: %v", c)
+	}
+}
+
+// Tests that the queues of digests which fail to be sent are restored, along
+// with updates queued meanwhile.
+func TestSendDigestsFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "digests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	digestRoot := settings.DigestRoot
+	settings.DigestRoot = dir + "/"
+	defer func() { settings.DigestRoot = digestRoot }()
+	sender := settings.Global.SenderMail
+	settings.Global.SenderMail.Sendmail = "false"
+	defer func() { settings.Global.SenderMail = sender }()
+
+	to := []string{"team@example.org"}
+	sched := &Schedule{Interval: time.Hour}
+	u, _ := url.Parse("http://example.org/a")
+	if err = enqueue("team", to, nil, sched, &Event{URL: u, Diff: "+ new\n"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Queues which are being sent don't block new updates.
+	paths, err := takeDue(time.Now().Add(2 * time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 1 {
+		t.Fatalf("output `%v` != expected `%v`", len(paths), 1)
+	}
+	u, _ = url.Parse("http://example.org/b")
+	if err = enqueue("team", to, nil, sched, &Event{URL: u, Diff: "+ new\n"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = restoreQueue(paths[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = SendDigests(time.Now().Add(2 * time.Hour)); err == nil {
+		t.Fatal("expected error for failing sendmail")
+	}
+	q, err := loadQueue(queuePath("team", to))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.Updates) != 2 || q.Updates[0].URL != "http://example.org/a" || q.Updates[1].URL != "http://example.org/b" {
+		t.Errorf("unexpected queue: %v", q)
+	}
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fis) != 1 {
+		t.Errorf("output `%v` != expected `%v`", len(fis), 1)
+	}
+}
@@ -0,0 +1,130 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/karlek/nyfiken/diff"
+	"github.com/karlek/nyfiken/filter"
+	"github.com/karlek/nyfiken/settings"
+	"github.com/mewkiz/pkg/errutil"
+)
+
+// TypeExec runs a command for each update.
+const TypeExec = "exec"
+
+// Exec notifier field names.
+const (
+	fieldExecCommand = "command"
+	fieldExecTimeout = "timeout"
+)
+
+func init() {
+	Register(TypeExec, []string{fieldExecCommand, fieldExecTimeout}, newExec)
+}
+
+// Error messages.
+var (
+	errExecTimeout = "notify: %s: timeout after %v: %s"
+	errExecFailure = "notify: %s: %s: %v: %s"
+)
+
+// Exec is a notifier which runs a command for each update. The update is
+// described by environment variables (e.g. NYFIKEN_URL) and the full diff is
+// written to standard input of the command.
+type Exec struct {
+	name    string
+	Cmd     *filter.Cmd   // Command to run.
+	Timeout time.Duration // Duration until the command is killed.
+}
+
+// newExec creates an exec notifier from its configuration.
+func newExec(conf *settings.Notifier) (Notifier, error) {
+	command, found := conf.Fields[fieldExecCommand]
+	if !found {
+		return nil, errutil.NewNoPosf(errRequiredFieldMiss, conf.Name, fieldExecCommand)
+	}
+	cmd, err := filter.Parse(filter.TypeCmd + filter.TypeSep + command)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+
+	e := &Exec{name: conf.Name, Cmd: cmd, Timeout: settings.DefaultFilterTimeout}
+	if s, found := conf.Fields[fieldExecTimeout]; found {
+		e.Timeout, err = time.ParseDuration(s)
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+	}
+	return e, nil
+}
+
+// Name returns the name of the notifier.
+func (x *Exec) Name() string {
+	return x.name
+}
+
+// Notify runs the command of the notifier for the update. The output of the
+// command is logged.
+func (x *Exec) Notify(ctx context.Context, e *Event) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, x.Timeout)
+	defer cancel()
+
+	in, err := input(e)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	c := exec.CommandContext(ctx, x.Cmd.Path, x.Cmd.Args...)
+	c.Env = append(os.Environ(), Env(e)...)
+	c.Stdin = strings.NewReader(in)
+	var out bytes.Buffer
+	c.Stdout = &out
+	c.Stderr = &out
+
+	err = c.Run()
+	if output := strings.TrimSpace(out.String()); output != "" {
+		log.Printf("notify: %s: %s", x.name, output)
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return errutil.NewNoPosf(errExecTimeout, x.name, x.Timeout, x.Cmd)
+	}
+	if err != nil {
+		return errutil.NewNoPosf(errExecFailure, x.name, x.Cmd, err, strings.TrimSpace(out.String()))
+	}
+	return nil
+}
+
+// Env returns the environment variables describing an update.
+func Env(e *Event) []string {
+	return []string{
+		"NYFIKEN_URL=" + e.URL.String(),
+		"NYFIKEN_HOST=" + e.URL.Host,
+		"NYFIKEN_TITLE=" + e.Title,
+		"NYFIKEN_TIME=" + e.Time.Format(time.RFC3339),
+		"NYFIKEN_SCORE=" + strconv.FormatFloat(e.Score, 'f', -1, 64),
+		"NYFIKEN_SNAPSHOT=" + e.Snapshot,
+		"NYFIKEN_DIFF=" + e.Diff,
+		"NYFIKEN_OLD=" + e.OldPath,
+		"NYFIKEN_NEW=" + e.NewPath,
+	}
+}
+
+// input returns the standard input of the command of an update: the full diff
+// between its snapshots, or the diff excerpt of updates without snapshots, e.g.
+// of watched responses.
+func input(e *Event) (in string, err error) {
+	lines, err := e.lines()
+	if err != nil {
+		return "", errutil.Err(err)
+	}
+	if lines == nil {
+		return e.Diff, nil
+	}
+	return diff.Unified(lines, settings.DefaultDiffContext), nil
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"context"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/karlek/nyfiken/settings"
+)
+
+// Tests Exec.Notify
+func TestExec(t *testing.T) {
+	f, err := ioutil.TempFile("", "exec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	os.Setenv("NYFIKEN_TEST_OUT", f.Name())
+	defer os.Unsetenv("NYFIKEN_TEST_OUT")
+
+	dir, err := ioutil.TempDir("", "snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oldPath := filepath.Join(dir, "old.htm")
+	newPath := filepath.Join(dir, "new.htm")
+	if err = ioutil.WriteFile(oldPath, []byte("a\nold\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(newPath, []byte("a\nnew\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.Parse("http://example.org/news")
+	e := &Event{URL: u, Score: 4.5, Diff: "- old\n+ new\n", OldPath: oldPath, NewPath: newPath}
+
+	n, err := New(&settings.Notifier{Name: "script", Type: TypeExec, Fields: map[string]string{
+		"command": `sh -c 'cat > "$NYFIKEN_TEST_OUT"; echo "$NYFIKEN_HOST $NYFIKEN_SCORE $NYFIKEN_DIFF" >> "$NYFIKEN_TEST_OUT"'`,
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = n.Notify(context.Background(), e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "@@ -1,2 +1,2 @@\n  a\n- old\n+ new\nexample.org 4.5 - old\n+ new\n\n"; string(buf) != expected {
+		t.Errorf("output `%v` != expected `%v`", string(buf), expected)
+	}
+
+	// Commands are killed after the timeout.
+	n, err = New(&settings.Notifier{Name: "slow", Type: TypeExec, Fields: map[string]string{
+		"command": "sleep 5",
+		"timeout": "50ms",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err = n.Notify(context.Background(), e); err == nil {
+		t.Errorf("expected timeout error")
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Errorf("command wasn't killed after the timeout")
+	}
+
+	if _, err = New(&settings.Notifier{Name: "none", Type: TypeExec}); err == nil {
+		t.Errorf("expected error for missing command")
+	}
+}
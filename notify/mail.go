@@ -0,0 +1,256 @@
+package notify
+
+import (
+	"context"
+	"html/template"
+	"io/ioutil"
+	"strconv"
+
+	"github.com/karlek/nyfiken/diff"
+	"github.com/karlek/nyfiken/mail"
+	"github.com/karlek/nyfiken/settings"
+	"github.com/mewkiz/pkg/errutil"
+)
+
+// TypeMail mails updates using the [mail] section of config.ini.
+const TypeMail = "mail"
+
+// Mail notifier field names.
+const (
+	fieldMailRecvMail     = "recvmail"
+	fieldMailCC           = "cc"
+	fieldMailSubject      = "subject"
+	fieldMailTextTemplate = "texttemplate"
+	fieldMailHTMLTemplate = "htmltemplate"
+	fieldMailDiffContext  = "diffcontext"
+	fieldMailDigest       = "digest"
+)
+
+// Maximum number of lines of the diffs in notification mails.
+const maxMailDiffLines = 500
+
+// Error messages.
+var (
+	errNoRecipients       = "notify: %s: no mail address to send notifications to."
+	errNoSenderMail       = "notify: %s: no [mail] section found in config.ini."
+	errInvalidDiffContext = "notify: %s: invalid diff context: `%s`."
+)
+
+// Mail is a notifier which mails the contents of updates.
+type Mail struct {
+	name        string
+	To          []string        // Mail addresses to send notifications to.
+	Cc          []string        // Mail addresses to send copies of notifications to.
+	Templates   *mail.Templates // Templates of the notification mails.
+	DiffContext int             // Number of unchanged lines around the changes in diffs.
+	Digest      *Schedule       // Schedule of digests; nil mails each update.
+}
+
+// NewMail returns a mail notifier which sends notifications to the recipients
+// to with copies to cc, formatted by the templates of the [mail] section.
+// Recipients are mail addresses or names of recipient groups.
+func NewMail(name string, to, cc []string) (m *Mail, err error) {
+	to, err = mail.ParseRecipients(to, settings.Global.Groups)
+	if err != nil {
+		return nil, errutil.NewNoPosf("notify: %s: %v", name, err)
+	}
+	if len(to) == 0 {
+		return nil, errutil.NewNoPosf(errNoRecipients, name)
+	}
+	cc, err = mail.ParseRecipients(cc, settings.Global.Groups)
+	if err != nil {
+		return nil, errutil.NewNoPosf("notify: %s: %v", name, err)
+	}
+	sender := settings.Global.SenderMail
+	if sender.Address == "" || (sender.OutServer == "" && sender.Sendmail == "") {
+		return nil, errutil.NewNoPosf(errNoSenderMail, name)
+	}
+	tmpl := settings.Global.MailTemplate
+	t, err := mail.ParseTemplates(tmpl.Subject, tmpl.Text, tmpl.HTML)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	digest, err := ParseSchedule(settings.Global.Digest)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	m = &Mail{
+		name:        name,
+		To:          to,
+		Cc:          cc,
+		Templates:   t,
+		DiffContext: settings.Global.DiffContext,
+		Digest:      digest,
+	}
+	return m, nil
+}
+
+// MailConf returns the configuration of a mail notifier which sends
+// notifications to the recipients to with copies to cc.
+func MailConf(to, cc []string) *settings.Notifier {
+	conf := &settings.Notifier{
+		Name:  TypeMail,
+		Type:  TypeMail,
+		Lists: map[string][]string{fieldMailRecvMail: to},
+	}
+	if len(cc) > 0 {
+		conf.Lists[fieldMailCC] = cc
+	}
+	return conf
+}
+
+// newMail creates a mail notifier from its configuration. The receiving mail
+// addresses and the templates default to those of the [mail] section.
+func newMail(conf *settings.Notifier) (Notifier, error) {
+	to := recipients(conf, fieldMailRecvMail)
+	if to == nil {
+		to = settings.Global.RecvMail
+	}
+	m, err := NewMail(conf.Name, to, recipients(conf, fieldMailCC))
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	if s, found := conf.Fields[fieldMailDiffContext]; found {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return nil, errutil.NewNoPosf(errInvalidDiffContext, conf.Name, s)
+		}
+		m.DiffContext = n
+	}
+	if s, found := conf.Fields[fieldMailDigest]; found {
+		m.Digest, err = ParseSchedule(s)
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+	}
+
+	_, hasSubject := conf.Fields[fieldMailSubject]
+	_, hasText := conf.Fields[fieldMailTextTemplate]
+	_, hasHTML := conf.Fields[fieldMailHTMLTemplate]
+	if !hasSubject && !hasText && !hasHTML {
+		return m, nil
+	}
+	tmpl := settings.Global.MailTemplate
+	if hasSubject {
+		tmpl.Subject = conf.Fields[fieldMailSubject]
+	}
+	if hasText {
+		if tmpl.Text, err = readFile(conf.Fields[fieldMailTextTemplate]); err != nil {
+			return nil, errutil.Err(err)
+		}
+	}
+	if hasHTML {
+		if tmpl.HTML, err = readFile(conf.Fields[fieldMailHTMLTemplate]); err != nil {
+			return nil, errutil.Err(err)
+		}
+	}
+	m.Templates, err = mail.ParseTemplates(tmpl.Subject, tmpl.Text, tmpl.HTML)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	return m, nil
+}
+
+// recipients returns the recipients of a field, which is either a single value
+// or a list.
+func recipients(conf *settings.Notifier, field string) []string {
+	if list, found := conf.Lists[field]; found {
+		return list
+	}
+	if s, found := conf.Fields[field]; found {
+		return []string{s}
+	}
+	return nil
+}
+
+// readFile returns the contents of a file.
+func readFile(path string) (s string, err error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", errutil.Err(err)
+	}
+	return string(buf), nil
+}
+
+// Name returns the name of the notifier.
+func (m *Mail) Name() string {
+	return m.name
+}
+
+// Notify mails the update to the user, or queues it if digests are enabled.
+func (m *Mail) Notify(ctx context.Context, e *Event) (err error) {
+	if m.Digest != nil {
+		return enqueue(m.name, m.To, m.Cc, m.Digest, e)
+	}
+	u, err := mailUpdate(e, m.DiffContext)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	err = mail.Send(m.To, m.Cc, u, m.Templates)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	return nil
+}
+
+// Consumes reports that mailed updates are considered read, since the mail
+// contains the update. Queued updates are considered read once their digest is
+// sent.
+func (m *Mail) Consumes() bool {
+	return m.Digest == nil
+}
+
+// mailUpdate returns the description of an update to mail templates. The
+// changes are the differences between the snapshots of the update, with
+// context lines of unchanged lines around them.
+func mailUpdate(e *Event, context int) (u *mail.Update, err error) {
+	host := e.URL.Host
+	if host == "" {
+		host = e.URL.String()
+	}
+	u = &mail.Update{
+		URL:      e.URL,
+		Host:     host,
+		Title:    e.Title,
+		Time:     e.Time,
+		Score:    e.Score,
+		Body:     template.HTML(e.Body),
+		Diff:     e.Diff,
+		Snapshot: e.Snapshot,
+	}
+
+	// Updates of watched responses have no snapshots.
+	lines, err := e.lines()
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	if lines == nil {
+		return u, nil
+	}
+	u.Hunks, u.Truncated = limitHunks(diff.Hunks(lines, context), maxMailDiffLines)
+	// The full version is the page itself, since snapshots are local to the
+	// daemon and pruned; the output of commands and local files can't be
+	// linked to.
+	if e.URL.Scheme == "http" || e.URL.Scheme == "https" {
+		u.Full = template.URL(e.URL.String())
+	}
+	return u, nil
+}
+
+// limitHunks returns the hunks truncated to at most max lines, and whether they
+// were truncated.
+func limitHunks(hunks []diff.Hunk, max int) (limited []diff.Hunk, truncated bool) {
+	var n int
+	for _, h := range hunks {
+		if n+len(h.Lines) > max {
+			h.Lines = h.Lines[:max-n]
+			if len(h.Lines) > 0 {
+				limited = append(limited, h)
+			}
+			return limited, true
+		}
+		n += len(h.Lines)
+		limited = append(limited, h)
+	}
+	return limited, false
+}
@@ -0,0 +1,104 @@
+package notify
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/karlek/nyfiken/diff"
+)
+
+// Tests mailUpdate
+func TestMailUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oldPath := filepath.Join(dir, "old.htm")
+	newPath := filepath.Join(dir, "new.htm")
+	if err = ioutil.WriteFile(oldPath, []byte("a\nb\nc\nd\ne\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(newPath, []byte("a\nb\nx\nd\ne\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	u, _ := url.Parse("exec:echo nyfiken")
+	e := &Event{URL: u, OldPath: oldPath, NewPath: newPath}
+	update, err := mailUpdate(e, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update.Host != "exec:echo nyfiken" {
+		t.Errorf("output `%v` != expected `%v`", update.Host, "exec:echo nyfiken")
+	}
+	if len(update.Hunks) != 1 || len(update.Hunks[0].Lines) != 4 {
+		t.Errorf("unexpected hunks: %v", update.Hunks)
+	}
+	// The output of commands can't be linked to.
+	if update.Full != "" {
+		t.Errorf("output `%v` != expected `%v`", update.Full, "")
+	}
+	e.URL, _ = url.Parse("http://example.org/news")
+	if update, err = mailUpdate(e, 1); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "http://example.org/news"; string(update.Full) != expected {
+		t.Errorf("output `%v` != expected `%v`", update.Full, expected)
+	}
+
+	// The differences computed by the check are used rather than the
+	// snapshots, which may have been pruned.
+	e.Lines = diff.Lines("a\nb\n", "a\nc\n")
+	e.OldPath, e.NewPath = oldPath+".pruned", newPath+".pruned"
+	if update, err = mailUpdate(e, 1); err != nil {
+		t.Fatal(err)
+	}
+	if len(update.Hunks) != 1 || len(update.Hunks[0].Lines) != 3 {
+		t.Errorf("unexpected hunks: %v", update.Hunks)
+	}
+
+	// Updates of watched responses have no snapshots.
+	update, err = mailUpdate(&Event{URL: u, Diff: "+ Status: 404\n"}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if update.Hunks != nil || update.Full != "" {
+		t.Errorf("unexpected diff of update without snapshots: %v", update)
+	}
+}
+
+// Tests limitHunks
+func TestLimitHunks(t *testing.T) {
+	hunks := diff.Hunks(diff.Lines("a\nb\nc\nd\ne\nf\ng\n", "x\nb\nc\nd\ne\nf\ny\n"), 0)
+	var testTable = []struct {
+		max       int
+		lines     []int
+		truncated bool
+	}{
+		{10, []int{2, 2}, false},
+		{4, []int{2, 2}, false},
+		{3, []int{2, 1}, true},
+		{2, []int{2}, true},
+	}
+	for _, test := range testTable {
+		limited, truncated := limitHunks(hunks, test.max)
+		var lines []int
+		for _, h := range limited {
+			lines = append(lines, len(h.Lines))
+		}
+		if truncated != test.truncated || len(lines) != len(test.lines) {
+			t.Errorf("output `%v, %v` != expected `%v, %v`", lines, truncated, test.lines, test.truncated)
+			continue
+		}
+		for i := range lines {
+			if lines[i] != test.lines[i] {
+				t.Errorf("output `%v` != expected `%v`", lines, test.lines)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,206 @@
+// Package notify notifies the user about updated pages through different
+// channels, e.g. mail. Notifier types are registered by name and configured in
+// [notify.<name>] sections of config.ini.
+package notify
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/karlek/nyfiken/diff"
+	"github.com/karlek/nyfiken/settings"
+	"github.com/mewkiz/pkg/errutil"
+)
+
+// Field of a notifier section which contains its type.
+const FieldType = "type"
+
+// Error messages.
+var (
+	errInvalidType       = "notify: invalid notifier type `%s`; valid types: %v."
+	errInvalidName       = "notify: invalid notifier type name `%s`."
+	errDuplicateType     = "notify: notifier type `%s` already registered."
+	errFieldNotExist     = "notify: %s: field `%s` doesn't exist for type `%s`."
+	errNotifierNotExist  = "notify: notifier `%s` doesn't exist in config.ini."
+	errRequiredFieldMiss = "notify: %s: field `%s` required."
+)
+
+// An Event describes an update of a page.
+type Event struct {
+	URL   *url.URL  // URL of the updated page.
+	Title string    // Title of the updated page.
+	Time  time.Time // Time of the check which detected the update.
+	Score float64   // Distance between the previous and the current check.
+	Body  string    // HTML body describing the update, e.g. the new selection.
+
+	Diff     string      // Excerpt of the changed lines, prefixed with `+ ` or `- `.
+	Lines    []diff.Line // Differences between the snapshots, if computed by the check.
+	Snapshot string      // Identifier of the current check, i.e. a hash of its selection.
+	OldPath  string      // Path of the snapshot of the previous check.
+	NewPath  string      // Path of the snapshot of the current check.
+}
+
+// lines returns the differences between the snapshots of an update. They are
+// computed from the snapshots for events which don't include them; nil is
+// returned for updates without snapshots, e.g. of watched responses.
+func (e *Event) lines() (lines []diff.Line, err error) {
+	if e.Lines != nil || e.OldPath == "" || e.NewPath == "" {
+		return e.Lines, nil
+	}
+	old, err := readFile(e.OldPath)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	cur, err := readFile(e.NewPath)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	return diff.Lines(old, cur), nil
+}
+
+// A Notifier notifies the user about updates through a channel.
+type Notifier interface {
+	// Name returns the name of the notifier.
+	Name() string
+	// Notify notifies the user about the update e.
+	Notify(ctx context.Context, e *Event) error
+}
+
+// A Consumer is a notifier which delivers the contents of updates, so that they
+// are removed from the list of unread updates once delivered, e.g. mail.
+type Consumer interface {
+	Notifier
+	// Consumes reports whether delivered updates are considered read.
+	Consumes() bool
+}
+
+// A PermanentError is a notification error which retrying can't resolve, e.g.
+// a request rejected by the receiving server.
+type PermanentError struct {
+	Err error
+}
+
+// Permanent returns err marked as a permanent error.
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+// IsPermanent reports whether err is a permanent error.
+func IsPermanent(err error) bool {
+	_, ok := err.(*PermanentError)
+	return ok
+}
+
+// A Factory creates a notifier from its configuration.
+type Factory func(conf *settings.Notifier) (Notifier, error)
+
+// A notifierType is a registered notifier type.
+type notifierType struct {
+	fields map[string]bool // Valid fields of the type, besides its type.
+	create Factory
+}
+
+var (
+	// mu protects types.
+	mu sync.RWMutex
+
+	// types maps type names to registered notifier types.
+	types = make(map[string]notifierType)
+)
+
+func init() {
+	Register(TypeMail, []string{
+		fieldMailRecvMail,
+		fieldMailCC,
+		fieldMailSubject,
+		fieldMailTextTemplate,
+		fieldMailHTMLTemplate,
+		fieldMailDiffContext,
+		fieldMailDigest,
+	}, newMail)
+}
+
+// Register makes a notifier type available by the provided name, with the
+// provided valid fields. It panics if the name is empty or already registered.
+func Register(typ string, fields []string, create Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if typ == "" {
+		panic(errutil.NewNoPosf(errInvalidName, typ))
+	}
+	if _, found := types[typ]; found {
+		panic(errutil.NewNoPosf(errDuplicateType, typ))
+	}
+	t := notifierType{fields: make(map[string]bool), create: create}
+	for _, field := range fields {
+		t.fields[field] = true
+	}
+	types[typ] = t
+}
+
+// New creates a notifier from its configuration. The fields of the
+// configuration are validated against the fields of its type.
+func New(conf *settings.Notifier) (n Notifier, err error) {
+	mu.RLock()
+	t, found := types[conf.Type]
+	mu.RUnlock()
+	if !found {
+		return nil, errutil.NewNoPosf(errInvalidType, conf.Type, Types())
+	}
+
+	for field := range conf.Fields {
+		if !t.fields[field] {
+			return nil, errutil.NewNoPosf(errFieldNotExist, conf.Name, field, conf.Type)
+		}
+	}
+	for field := range conf.Lists {
+		if !t.fields[field] {
+			return nil, errutil.NewNoPosf(errFieldNotExist, conf.Name, field, conf.Type)
+		}
+	}
+
+	n, err = t.create(conf)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	return n, nil
+}
+
+// Get creates the notifier configured in config.ini by the provided name.
+func Get(name string) (n Notifier, err error) {
+	conf, err := Lookup(name)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	return New(conf)
+}
+
+// Lookup returns the configuration of the notifier configured in config.ini by
+// the provided name.
+func Lookup(name string) (conf *settings.Notifier, err error) {
+	conf, found := settings.Global.Notifiers[name]
+	if !found {
+		return nil, errutil.NewNoPosf(errNotifierNotExist, name)
+	}
+	return conf, nil
+}
+
+// Types returns the sorted names of all registered notifier types.
+func Types() (names []string) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for typ := range types {
+		names = append(names, typ)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"context"
+	"testing"
+
+	"github.com/karlek/nyfiken/settings"
+)
+
+// recorder is a notifier which records events.
+type recorder struct {
+	name   string
+	events []*Event
+}
+
+func (r *recorder) Name() string {
+	return r.name
+}
+
+func (r *recorder) Notify(ctx context.Context, e *Event) error {
+	r.events = append(r.events, e)
+	return nil
+}
+
+// Tests Register and New
+func TestNew(t *testing.T) {
+	Register("recorder", []string{"channel"}, func(conf *settings.Notifier) (Notifier, error) {
+		return &recorder{name: conf.Name}, nil
+	})
+
+	settings.Global.SenderMail.Address = "sender@example.org"
+	settings.Global.SenderMail.AuthServer = "auth.example.org"
+	settings.Global.SenderMail.OutServer = "out.example.org:587"
+
+	var testTable = []struct {
+		conf  *settings.Notifier
+		valid bool
+	}{
+		{&settings.Notifier{Name: "rec", Type: "recorder", Fields: map[string]string{"channel": "#nyfiken"}}, true},
+		{&settings.Notifier{Name: "rec", Type: "recorder", Fields: map[string]string{"colour": "red"}}, false},
+		{&settings.Notifier{Name: "rec", Type: "recorder", Lists: map[string][]string{"channel": {"#a", "#b"}}}, true},
+		{&settings.Notifier{Name: "pigeon", Type: "pigeon"}, false},
+		{&settings.Notifier{Name: "team", Type: TypeMail, Fields: map[string]string{"recvmail": "team@example.org"}}, true},
+		{&settings.Notifier{Name: "team", Type: TypeMail, Fields: map[string]string{"recvmail": "team"}}, false},
+		{&settings.Notifier{Name: "team", Type: TypeMail, Lists: map[string][]string{"recvmail": {"a@example.org", "B <b@example.org>"}, "cc": {"c@example.org"}}}, true},
+		{&settings.Notifier{Name: "team", Type: TypeMail, Lists: map[string][]string{"cc": {"not an address"}}}, false},
+	}
+	for _, test := range testTable {
+		n, err := New(test.conf)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: output valid `%v` != expected `%v`; %v", test.conf.Type, err == nil, test.valid, err)
+			continue
+		}
+		if err == nil && n.Name() != test.conf.Name {
+			t.Errorf("output `%v` != expected `%v`", n.Name(), test.conf.Name)
+		}
+	}
+
+	// Registering a type twice panics.
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic for duplicate type")
+		}
+	}()
+	Register("recorder", nil, nil)
+}
@@ -0,0 +1,359 @@
+package notify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/gob"
+	"encoding/hex"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/karlek/nyfiken/diff"
+	"github.com/karlek/nyfiken/settings"
+	"github.com/mewkiz/pkg/errutil"
+)
+
+// Extension of deliveries in the outbox.
+const outboxExt = ".gob"
+
+// Extension appended to deliveries which can't be decoded, so that they are
+// set aside rather than attempted again.
+const corruptExt = ".corrupt"
+
+// Interval between checks for due deliveries, unless woken by Wake.
+const outboxPoll = 10 * time.Second
+
+// Error messages.
+var (
+	errCorruptDelivery = "notify: corrupt delivery `%s` moved to `%s`: %v"
+)
+
+// wake wakes the sender of the outbox when a delivery has been posted.
+var wake = make(chan struct{}, 1)
+
+// A delivery is a notification of an event by a notifier, persisted in the
+// outbox until the notifier has succeeded. Only the name of the notifier is
+// persisted, since its configuration may contain secrets; the current
+// configuration is used for each attempt.
+type delivery struct {
+	Notifier  string    // Name of the notifier in config.ini; empty for mail to To.
+	To        []string  // Recipients of notifications without a named notifier.
+	Cc        []string  // Copy recipients of notifications without a named notifier.
+	Event     record    // Event to notify about.
+	Created   time.Time // Time the delivery was posted.
+	Attempts  int       // Number of failed attempts.
+	Next      time.Time // Time of the next attempt.
+	LastError string    // Error of the last failed attempt.
+}
+
+// A record is an event as persisted in the outbox.
+type record struct {
+	URL      string
+	Title    string
+	Time     time.Time
+	Score    float64
+	Body     string
+	Diff     string
+	Lines    []diff.Line
+	Snapshot string
+	OldPath  string
+	NewPath  string
+}
+
+// Post writes the notification of an event by the notifier configured in
+// config.ini by the provided name to the outbox, from which it is delivered by
+// RunOutbox. Notifications are retried until they succeed, so that they are
+// delivered at least once.
+func Post(name string, e *Event) (err error) {
+	return post(&delivery{Notifier: name}, e)
+}
+
+// PostMail writes the notification of an event by mail to the recipients to,
+// with copies to cc, to the outbox.
+func PostMail(to, cc []string, e *Event) (err error) {
+	return post(&delivery{To: to, Cc: cc}, e)
+}
+
+// post writes a delivery of an event to the outbox.
+func post(d *delivery, e *Event) (err error) {
+	now := time.Now()
+	d.Event = record{
+		URL:      e.URL.String(),
+		Title:    e.Title,
+		Time:     e.Time,
+		Score:    e.Score,
+		Body:     e.Body,
+		Diff:     e.Diff,
+		Lines:    e.Lines,
+		Snapshot: e.Snapshot,
+		OldPath:  e.OldPath,
+		NewPath:  e.NewPath,
+	}
+	d.Created = now
+	d.Next = now
+
+	// Deliveries are named by the time they were posted, so that they are
+	// delivered in order.
+	buf := make([]byte, 4)
+	if _, err = rand.Read(buf); err != nil {
+		return errutil.Err(err)
+	}
+	name := strconv.FormatInt(now.UnixNano(), 10) + "-" + hex.EncodeToString(buf) + outboxExt
+	err = saveDelivery(settings.OutboxRoot+name, d)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	return nil
+}
+
+// Wake makes RunOutbox deliver posted notifications immediately.
+func Wake() {
+	select {
+	case wake <- struct{}{}:
+	default:
+	}
+}
+
+// RunOutbox delivers the notifications of the outbox as they become due. It
+// never returns.
+func RunOutbox() {
+	for {
+		_, err := startDeliveries(time.Now())
+		if err != nil {
+			log.Println(errutil.Err(err))
+		}
+		select {
+		case <-wake:
+		case <-time.After(outboxPoll):
+		}
+	}
+}
+
+// DeliverOutbox attempts the deliveries of the outbox which are due at now, and
+// waits for the attempts to finish. Failed deliveries are retried with an
+// increasing delay, and abandoned after the maximum age of deliveries.
+func DeliverOutbox(now time.Time) (err error) {
+	wg, err := startDeliveries(now)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	wg.Wait()
+	return nil
+}
+
+var (
+	// busyMu protects busy.
+	busyMu sync.Mutex
+
+	// busy contains the names of the notifiers whose deliveries are being
+	// attempted.
+	busy = make(map[string]bool)
+)
+
+// startDeliveries starts attempting the deliveries of the outbox which are due
+// at now. The deliveries of each notifier are attempted in order, concurrently
+// with those of other notifiers, so that a slow or failing notifier doesn't
+// hold up the others. Notifiers whose previous deliveries are still being
+// attempted are skipped. The returned wait group is done once all started
+// attempts have finished.
+func startDeliveries(now time.Time) (wg *sync.WaitGroup, err error) {
+	fis, err := ioutil.ReadDir(settings.OutboxRoot)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	busyMu.Lock()
+	defer busyMu.Unlock()
+
+	groups := make(map[string][]string)
+	for _, fi := range fis {
+		// Skip deliveries which are being written.
+		if !strings.HasSuffix(fi.Name(), outboxExt) {
+			continue
+		}
+		path := settings.OutboxRoot + fi.Name()
+		d, err := loadDelivery(path)
+		if err != nil {
+			log.Println(errutil.Err(err))
+			continue
+		}
+		if busy[d.name()] || now.Before(d.Next) {
+			continue
+		}
+		groups[d.name()] = append(groups[d.name()], path)
+	}
+
+	wg = new(sync.WaitGroup)
+	for name, paths := range groups {
+		busy[name] = true
+		wg.Add(1)
+		go func(name string, paths []string) {
+			defer wg.Done()
+			for _, path := range paths {
+				err := deliver(path, now)
+				if err != nil {
+					log.Println(errutil.Err(err))
+				}
+			}
+			busyMu.Lock()
+			delete(busy, name)
+			busyMu.Unlock()
+		}(name, paths)
+	}
+	return wg, nil
+}
+
+// deliver attempts a delivery if it's due at now. The delivery is removed from
+// the outbox if it succeeds, fails permanently or is abandoned. Updates
+// delivered by a consuming notifier, e.g. mail, are removed from the list of
+// unread updates.
+func deliver(path string, now time.Time) (err error) {
+	d, err := loadDelivery(path)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	if now.Before(d.Next) {
+		return nil
+	}
+
+	n, notifyErr := d.notifier()
+	if notifyErr == nil {
+		notifyErr = notifyRecord(n, &d.Event)
+	}
+	if notifyErr == nil {
+		err = os.Remove(path)
+		if err != nil {
+			return errutil.Err(err)
+		}
+		if c, ok := n.(Consumer); ok && c.Consumes() {
+			return settings.MarkRead(d.Event.URL)
+		}
+		return nil
+	}
+
+	d.Attempts++
+	d.LastError = notifyErr.Error()
+	if IsPermanent(notifyErr) || now.Sub(d.Created) > settings.OutboxMaxAge {
+		log.Printf("notify: %s: abandoning notification about %s after %d attempts: %v", d.name(), d.Event.URL, d.Attempts, notifyErr)
+		return os.Remove(path)
+	}
+	d.Next = now.Add(backoff(d.Attempts))
+	err = saveDelivery(path, d)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	return errutil.NewNoPosf("notify: %s: attempt %d failed; retrying at %s: %v", d.name(), d.Attempts, d.Next.Format(time.Kitchen), notifyErr)
+}
+
+// notifier creates the notifier of a delivery from its current configuration.
+// Notifiers which have been removed from config.ini or are invalid fail
+// permanently.
+func (d *delivery) notifier() (n Notifier, err error) {
+	conf := MailConf(d.To, d.Cc)
+	if d.Notifier != "" {
+		conf, err = Lookup(d.Notifier)
+		if err != nil {
+			return nil, Permanent(err)
+		}
+	}
+	n, err = New(conf)
+	if err != nil {
+		return nil, Permanent(err)
+	}
+	return n, nil
+}
+
+// name returns the name of the notifier of a delivery.
+func (d *delivery) name() string {
+	if d.Notifier == "" {
+		return TypeMail
+	}
+	return d.Notifier
+}
+
+// notifyRecord notifies the notifier about a persisted event. Events with an
+// invalid URL fail permanently.
+func notifyRecord(n Notifier, r *record) (err error) {
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return Permanent(errutil.Err(err))
+	}
+	e := &Event{
+		URL:      u,
+		Title:    r.Title,
+		Time:     r.Time,
+		Score:    r.Score,
+		Body:     r.Body,
+		Diff:     r.Diff,
+		Lines:    r.Lines,
+		Snapshot: r.Snapshot,
+		OldPath:  r.OldPath,
+		NewPath:  r.NewPath,
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), settings.NotifyTimeout)
+	defer cancel()
+	return n.Notify(ctx, e)
+}
+
+// backoff returns the delay before the next attempt of a delivery which has
+// failed the provided number of times; doubled for each attempt.
+func backoff(attempts int) time.Duration {
+	d := settings.OutboxBackoff
+	for i := 1; i < attempts && d < settings.OutboxMaxBackoff; i++ {
+		d *= 2
+	}
+	if d > settings.OutboxMaxBackoff {
+		d = settings.OutboxMaxBackoff
+	}
+	return d
+}
+
+// loadDelivery loads a delivery of the outbox. Deliveries which can't be
+// decoded are renamed with the corrupt extension, so that they are only
+// reported once.
+func loadDelivery(path string) (d *delivery, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	d = new(delivery)
+	decodeErr := gob.NewDecoder(f).Decode(d)
+	f.Close()
+	if decodeErr != nil {
+		err = os.Rename(path, path+corruptExt)
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+		return nil, errutil.NewNoPosf(errCorruptDelivery, path, path+corruptExt, decodeErr)
+	}
+	return d, nil
+}
+
+// saveDelivery saves a delivery to the outbox. It's written to a temporary file
+// first, so that partially written deliveries are never attempted.
+func saveDelivery(path string, d *delivery) (err error) {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, settings.Global.FilePerms)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	err = gob.NewEncoder(f).Encode(d)
+	if err != nil {
+		f.Close()
+		return errutil.Err(err)
+	}
+	err = f.Close()
+	if err != nil {
+		return errutil.Err(err)
+	}
+	err = os.Rename(tmp, path)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	return nil
+}
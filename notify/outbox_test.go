@@ -0,0 +1,273 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/karlek/nyfiken/settings"
+)
+
+// flaky is a notifier which fails with err while it's set, and records the
+// events it has been notified about.
+type flaky struct {
+	err    error
+	events []*Event
+}
+
+func (f *flaky) Name() string {
+	return "flaky"
+}
+
+func (f *flaky) Notify(ctx context.Context, e *Event) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.events = append(f.events, e)
+	return nil
+}
+
+// outboxLen returns the number of deliveries in the outbox.
+func outboxLen(t *testing.T) int {
+	fis, err := ioutil.ReadDir(settings.OutboxRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(fis)
+}
+
+// Tests that posted notifications are retried until they are delivered.
+func TestOutbox(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	outboxRoot := settings.OutboxRoot
+	settings.OutboxRoot = dir + "/"
+	defer func() { settings.OutboxRoot = outboxRoot }()
+
+	f := &flaky{err: errors.New("unavailable")}
+	Register("flaky", nil, func(conf *settings.Notifier) (Notifier, error) {
+		return f, nil
+	})
+	notifiers := settings.Global.Notifiers
+	settings.Global.Notifiers = map[string]*settings.Notifier{
+		"flaky": {Name: "flaky", Type: "flaky"},
+	}
+	defer func() { settings.Global.Notifiers = notifiers }()
+
+	u, _ := url.Parse("http://example.org/")
+	e := &Event{URL: u, Title: "Example", Diff: "+ new"}
+	err = Post("flaky", e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A failed attempt is kept in the outbox until it's due again.
+	now := time.Now()
+	if err = DeliverOutbox(now); err != nil {
+		t.Fatal(err)
+	}
+	if n := outboxLen(t); n != 1 {
+		t.Fatalf("output `%v` != expected `%v`", n, 1)
+	}
+	f.err = nil
+	if err = DeliverOutbox(now.Add(settings.OutboxBackoff / 2)); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.events) != 0 {
+		t.Errorf("output `%v` != expected `%v`", len(f.events), 0)
+	}
+
+	// The retry succeeds and removes the delivery.
+	if err = DeliverOutbox(now.Add(settings.OutboxBackoff)); err != nil {
+		t.Fatal(err)
+	}
+	if len(f.events) != 1 {
+		t.Fatalf("output `%v` != expected `%v`", len(f.events), 1)
+	}
+	if got := f.events[0]; got.URL.String() != u.String() || got.Title != e.Title || got.Diff != e.Diff {
+		t.Errorf("output `%v` != expected `%v`", got, e)
+	}
+	if n := outboxLen(t); n != 0 {
+		t.Errorf("output `%v` != expected `%v`", n, 0)
+	}
+
+	// Notifications are abandoned once they are too old.
+	f.err = errors.New("unavailable")
+	if err = Post("flaky", e); err != nil {
+		t.Fatal(err)
+	}
+	if err = DeliverOutbox(now.Add(settings.OutboxMaxAge + time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if n := outboxLen(t); n != 0 {
+		t.Errorf("output `%v` != expected `%v`", n, 0)
+	}
+
+	// Permanent errors aren't retried.
+	f.err = Permanent(errors.New("rejected"))
+	if err = Post("flaky", e); err != nil {
+		t.Fatal(err)
+	}
+	if err = DeliverOutbox(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if n := outboxLen(t); n != 0 {
+		t.Errorf("output `%v` != expected `%v`", n, 0)
+	}
+
+	// Notifications of notifiers removed from config.ini are abandoned.
+	f.err = nil
+	if err = Post("removed", e); err != nil {
+		t.Fatal(err)
+	}
+	if err = DeliverOutbox(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if n := outboxLen(t); n != 0 {
+		t.Errorf("output `%v` != expected `%v`", n, 0)
+	}
+	if len(f.events) != 1 {
+		t.Errorf("output `%v` != expected `%v`", len(f.events), 1)
+	}
+
+	// Notifications of invalid notifiers are abandoned.
+	settings.Global.Notifiers["invalid"] = &settings.Notifier{Name: "invalid", Type: "pigeon"}
+	if err = Post("invalid", e); err != nil {
+		t.Fatal(err)
+	}
+	if err = DeliverOutbox(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if n := outboxLen(t); n != 0 {
+		t.Errorf("output `%v` != expected `%v`", n, 0)
+	}
+}
+
+// Tests backoff
+func TestBackoff(t *testing.T) {
+	var testTable = []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, settings.OutboxBackoff},
+		{2, 2 * settings.OutboxBackoff},
+		{3, 4 * settings.OutboxBackoff},
+		{100, settings.OutboxMaxBackoff},
+	}
+	for _, test := range testTable {
+		output := backoff(test.attempts)
+		if output != test.expected {
+			t.Errorf("output `%v` != expected `%v`", output, test.expected)
+		}
+	}
+}
+
+// Tests that corrupt deliveries are set aside
+func TestCorruptDelivery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	outboxRoot := settings.OutboxRoot
+	settings.OutboxRoot = dir + "/"
+	defer func() { settings.OutboxRoot = outboxRoot }()
+
+	path := settings.OutboxRoot + "1-corrupt" + outboxExt
+	if err = ioutil.WriteFile(path, []byte("not a delivery"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = DeliverOutbox(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("corrupt delivery wasn't moved: %v", err)
+	}
+	if _, err = os.Stat(path + corruptExt); err != nil {
+		t.Error(err)
+	}
+}
+
+// blocking is a notifier which blocks until release is closed.
+type blocking struct {
+	release chan struct{}
+}
+
+func (b *blocking) Name() string {
+	return "blocking"
+}
+
+func (b *blocking) Notify(ctx context.Context, e *Event) error {
+	select {
+	case <-b.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Tests that a slow notifier doesn't hold up the deliveries of others
+func TestOutboxConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "outbox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	outboxRoot := settings.OutboxRoot
+	settings.OutboxRoot = dir + "/"
+	defer func() { settings.OutboxRoot = outboxRoot }()
+
+	b := &blocking{release: make(chan struct{})}
+	Register("blocking", nil, func(conf *settings.Notifier) (Notifier, error) {
+		return b, nil
+	})
+	r := &recorder{name: "fast"}
+	Register("fast", nil, func(conf *settings.Notifier) (Notifier, error) {
+		return r, nil
+	})
+	notifiers := settings.Global.Notifiers
+	settings.Global.Notifiers = map[string]*settings.Notifier{
+		"blocking": {Name: "blocking", Type: "blocking"},
+		"fast":     {Name: "fast", Type: "fast"},
+	}
+	defer func() { settings.Global.Notifiers = notifiers }()
+
+	u, _ := url.Parse("http://example.org/")
+	e := &Event{URL: u}
+	if err = Post("blocking", e); err != nil {
+		t.Fatal(err)
+	}
+	if err = Post("fast", e); err != nil {
+		t.Fatal(err)
+	}
+
+	wg, err := startDeliveries(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); outboxLen(t) != 1; {
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery of fast notifier held up by blocking notifier")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The busy notifier isn't attempted again until its delivery has finished.
+	wg2, err := startDeliveries(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(b.release)
+	wg.Wait()
+	wg2.Wait()
+	if n := outboxLen(t); n != 0 {
+		t.Errorf("output `%v` != expected `%v`", n, 0)
+	}
+}
@@ -0,0 +1,255 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/karlek/nyfiken/settings"
+	"github.com/mewkiz/pkg/errutil"
+)
+
+// TypeWebhook posts updates as JSON to a URL.
+const TypeWebhook = "webhook"
+
+// Webhook notifier field names.
+const (
+	fieldWebhookURL         = "url"
+	fieldWebhookHeader      = "header"
+	fieldWebhookSecret      = "secret"
+	fieldWebhookTemplate    = "template"
+	fieldWebhookContentType = "contenttype"
+	fieldWebhookRetries     = "retries"
+	fieldWebhookBackoff     = "backoff"
+)
+
+// Header containing the HMAC-SHA256 signature of the request body, keyed with
+// the secret of the webhook.
+const SignatureHeader = "X-Nyfiken-Signature"
+
+// Default values of webhooks.
+const (
+	defaultWebhookRetries = 3
+	defaultWebhookBackoff = 1 * time.Second
+)
+
+func init() {
+	Register(TypeWebhook, []string{
+		fieldWebhookURL,
+		fieldWebhookHeader,
+		fieldWebhookSecret,
+		fieldWebhookTemplate,
+		fieldWebhookContentType,
+		fieldWebhookRetries,
+		fieldWebhookBackoff,
+	}, newWebhook)
+}
+
+// Error messages.
+var (
+	errInvalidWebhookHeader = "notify: %s: invalid header: `%s`; correct syntax -> `HeaderName: Value`."
+	errInvalidRetries       = "notify: %s: invalid retries: `%s`."
+	errWebhookFailed        = "notify: %s: webhook failed: (%d) - %s"
+)
+
+// Webhook is a notifier which posts updates to a URL, e.g. of a chat system.
+// The body is a JSON object describing the update, unless a template is used.
+type Webhook struct {
+	name        string
+	URL         string             // URL to post updates to.
+	Header      map[string]string  // Additional HTTP headers.
+	Secret      string             // Key of the HMAC signature of the body.
+	Template    *template.Template // Template of the body.
+	ContentType string             // Content type of the body.
+	Retries     int                // Number of retries of failed requests.
+	Backoff     time.Duration      // Delay before the first retry, doubled for each retry.
+}
+
+// payload is the default JSON body of webhook requests.
+type payload struct {
+	URL      string    `json:"url"`
+	Title    string    `json:"title"`
+	Time     time.Time `json:"time"`
+	Score    float64   `json:"score"`
+	Diff     string    `json:"diff"`
+	Snapshot string    `json:"snapshot"`
+}
+
+// newWebhook creates a webhook notifier from its configuration.
+func newWebhook(conf *settings.Notifier) (Notifier, error) {
+	w := &Webhook{
+		name:        conf.Name,
+		URL:         conf.Fields[fieldWebhookURL],
+		Header:      make(map[string]string),
+		Secret:      conf.Fields[fieldWebhookSecret],
+		ContentType: conf.Fields[fieldWebhookContentType],
+		Retries:     defaultWebhookRetries,
+		Backoff:     defaultWebhookBackoff,
+	}
+
+	if w.URL == "" {
+		return nil, errutil.NewNoPosf(errRequiredFieldMiss, conf.Name, fieldWebhookURL)
+	}
+	if _, err := url.Parse(w.URL); err != nil {
+		return nil, errutil.Err(err)
+	}
+
+	for _, header := range conf.Lists[fieldWebhookHeader] {
+		if !strings.Contains(header, ":") {
+			return nil, errutil.NewNoPosf(errInvalidWebhookHeader, conf.Name, header)
+		}
+		keyVal := strings.SplitN(header, ":", 2)
+		w.Header[strings.TrimSpace(keyVal[0])] = strings.TrimSpace(keyVal[1])
+	}
+
+	if text, found := conf.Fields[fieldWebhookTemplate]; found {
+		t, err := template.New(conf.Name).Funcs(funcs).Parse(text)
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+		w.Template = t
+	}
+	if w.ContentType == "" {
+		w.ContentType = "application/json"
+	}
+
+	if s, found := conf.Fields[fieldWebhookRetries]; found {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return nil, errutil.NewNoPosf(errInvalidRetries, conf.Name, s)
+		}
+		w.Retries = n
+	}
+	if s, found := conf.Fields[fieldWebhookBackoff]; found {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+		w.Backoff = d
+	}
+
+	return w, nil
+}
+
+// Name returns the name of the notifier.
+func (w *Webhook) Name() string {
+	return w.name
+}
+
+// Notify posts the update to the URL of the webhook. Requests which fail with a
+// network error, a server error or too many requests are retried with
+// exponential backoff.
+func (w *Webhook) Notify(ctx context.Context, e *Event) (err error) {
+	body, err := w.body(e)
+	if err != nil {
+		return errutil.Err(err)
+	}
+
+	backoff := w.Backoff
+	for try := 0; ; try++ {
+		var retry bool
+		retry, err = w.post(ctx, body)
+		if err == nil || !retry || try == w.Retries {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return errutil.Err(ctx.Err())
+		}
+		backoff *= 2
+	}
+}
+
+// body returns the body of the request, rendered by the template of the webhook
+// or encoded as JSON.
+func (w *Webhook) body(e *Event) (body []byte, err error) {
+	if w.Template != nil {
+		buf := new(bytes.Buffer)
+		err = w.Template.Execute(buf, e)
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+		return buf.Bytes(), nil
+	}
+
+	body, err = json.Marshal(payload{
+		URL:      e.URL.String(),
+		Title:    e.Title,
+		Time:     e.Time,
+		Score:    e.Score,
+		Diff:     e.Diff,
+		Snapshot: e.Snapshot,
+	})
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	return body, nil
+}
+
+// post posts the body to the URL of the webhook and reports whether a failed
+// request may be retried. Requests rejected by the server fail with a permanent
+// error.
+func (w *Webhook) post(ctx context.Context, body []byte) (retry bool, err error) {
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, errutil.Err(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", w.ContentType)
+	req.Header.Set("User-Agent", "nyfiken")
+	for key, val := range w.Header {
+		req.Header.Set(key, val)
+	}
+	if w.Secret != "" {
+		req.Header.Set(SignatureHeader, Sign(w.Secret, body))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, errutil.Err(err)
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 1<<20))
+
+	if resp.StatusCode >= 300 {
+		err = errutil.NewNoPosf(errWebhookFailed, w.name, resp.StatusCode, resp.Status)
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			return true, err
+		}
+		return false, Permanent(err)
+	}
+	return false, nil
+}
+
+// Sign returns the signature of a webhook body: `sha256=` followed by the hex
+// encoded HMAC-SHA256 of the body, keyed with the secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// funcs are the functions available to templates.
+var funcs = template.FuncMap{
+	// json encodes a value as JSON, e.g. to quote strings in JSON templates.
+	"json": func(v interface{}) (string, error) {
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(buf), nil
+	},
+}
@@ -0,0 +1,103 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/karlek/nyfiken/settings"
+)
+
+// Tests Webhook.Notify
+func TestWebhook(t *testing.T) {
+	var bodies []string
+	var fails int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if fails > 0 {
+			fails--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("X-Team") != "nyfiken" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if sig := r.Header.Get(SignatureHeader); sig != Sign("s3cr3t", body) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		bodies = append(bodies, string(body))
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse("http://example.org/")
+	e := &Event{URL: u, Title: "Example", Time: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Score: 4.2, Diff: "+ new\n", Snapshot: "abc"}
+
+	conf := &settings.Notifier{
+		Name: "hook",
+		Type: TypeWebhook,
+		Fields: map[string]string{
+			"url":     ts.URL,
+			"secret":  "s3cr3t",
+			"backoff": "1ms",
+		},
+		Lists: map[string][]string{"header": {"X-Team: nyfiken"}},
+	}
+	n, err := New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Failed requests are retried.
+	fails = 2
+	err = n.Notify(context.Background(), e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bodies) != 1 {
+		t.Fatalf("output `%v` != expected `%v`", len(bodies), 1)
+	}
+	var p payload
+	if err = json.Unmarshal([]byte(bodies[0]), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.URL != "http://example.org/" || p.Title != "Example" || p.Score != 4.2 || p.Diff != "+ new\n" || p.Snapshot != "abc" {
+		t.Errorf("output `%+v` != expected event", p)
+	}
+
+	// Requests are retried until the retries are exhausted.
+	fails = 5
+	if err = n.Notify(context.Background(), e); err == nil || IsPermanent(err) {
+		t.Errorf("expected temporary error after retries; got %v", err)
+	}
+	fails = 0
+
+	// Templates shape the body for specific services.
+	conf.Fields["template"] = `{"text": {{json .Title}}}`
+	n, err = New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = n.Notify(context.Background(), e); err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"text": "Example"}`; bodies[len(bodies)-1] != expected {
+		t.Errorf("output `%v` != expected `%v`", bodies[len(bodies)-1], expected)
+	}
+
+	// Client errors aren't retried.
+	conf.Lists = nil
+	n, err = New(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = n.Notify(context.Background(), e); !IsPermanent(err) {
+		t.Errorf("expected permanent error for missing header; got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package page
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/mewkiz/pkg/errutil"
+)
+
+// acceptEncoding lists the content encodings which readBody can decode.
+const acceptEncoding = "gzip, deflate, br"
+
+// Error messages.
+var (
+	errTooLarge            = "response too large: %s; exceeds %d bytes."
+	errUnsupportedEncoding = "unsupported content encoding `%s`: %s"
+)
+
+// readBody reads the decoded body of the response. The content encodings
+// gzip, deflate and br (brotli) are supported. An error is returned if the
+// decoded body exceeds max bytes, unless max is 0.
+func readBody(resp *http.Response, max int64) (buf []byte, err error) {
+	var r io.Reader = resp.Body
+
+	// Encodings are listed in the order they were applied.
+	encodings := strings.Split(resp.Header.Get("Content-Encoding"), ",")
+	for i := len(encodings) - 1; i >= 0; i-- {
+		encoding := strings.ToLower(strings.TrimSpace(encodings[i]))
+		switch encoding {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			zr, err := gzip.NewReader(r)
+			if err != nil {
+				return nil, errutil.Err(err)
+			}
+			defer zr.Close()
+			r = zr
+		case "deflate":
+			// Deflate should be zlib wrapped, but some servers send raw
+			// deflate data.
+			br := bufio.NewReader(r)
+			header, err := br.Peek(2)
+			if err == nil && isZlibHeader(header) {
+				zr, err := zlib.NewReader(br)
+				if err != nil {
+					return nil, errutil.Err(err)
+				}
+				defer zr.Close()
+				r = zr
+			} else {
+				fr := flate.NewReader(br)
+				defer fr.Close()
+				r = fr
+			}
+		case "br":
+			r = brotli.NewReader(r)
+		default:
+			return nil, errutil.NewNoPosf(errUnsupportedEncoding, encoding, resp.Request.URL)
+		}
+	}
+
+	if max == 0 {
+		buf, err = ioutil.ReadAll(r)
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+		return buf, nil
+	}
+
+	// Read one byte more than allowed to detect if the body is too large.
+	buf, err = ioutil.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	if int64(len(buf)) > max {
+		return nil, errutil.NewNoPosf(errTooLarge, resp.Request.URL, max)
+	}
+	return buf, nil
+}
+
+// isZlibHeader reports whether the first two bytes of a stream is a zlib
+// header, as described in RFC 1950.
+func isZlibHeader(header []byte) bool {
+	cmf, flg := header[0], header[1]
+	return cmf&0x0f == 8 && (uint16(cmf)<<8|uint16(flg))%31 == 0
+}
@@ -0,0 +1,87 @@
+package page
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Tests readBody
+func TestReadBody(t *testing.T) {
+	const body = "<html><body>nyfiken</body></html>"
+
+	var testTable = []struct {
+		encoding string
+		compress func(w io.Writer) io.WriteCloser
+		max      int64
+		valid    bool
+	}{
+		{"", nil, 0, true},
+		{"identity", nil, int64(len(body)), true},
+		{"", nil, int64(len(body)) - 1, false},
+		{"gzip", func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }, 1024, true},
+		{"deflate", func(w io.Writer) io.WriteCloser { return zlib.NewWriter(w) }, 1024, true},
+		{"deflate", func(w io.Writer) io.WriteCloser {
+			fw, _ := flate.NewWriter(w, flate.DefaultCompression)
+			return fw
+		}, 1024, true},
+		{"br", func(w io.Writer) io.WriteCloser { return brotli.NewWriter(w) }, 1024, true},
+		// The limit applies to the decompressed body.
+		{"gzip", func(w io.Writer) io.WriteCloser { return gzip.NewWriter(w) }, 10, false},
+		{"compress", nil, 1024, false},
+	}
+
+	reqUrl, err := url.Parse("http://example.org")
+	if err != nil {
+		t.Fatalf("url.Parse: %s", err)
+	}
+	for _, test := range testTable {
+		raw := new(bytes.Buffer)
+		if test.compress != nil {
+			w := test.compress(raw)
+			w.Write([]byte(body))
+			w.Close()
+		} else {
+			raw.WriteString(body)
+		}
+		resp := &http.Response{
+			Header:  http.Header{"Content-Encoding": {test.encoding}},
+			Body:    ioutil.NopCloser(raw),
+			Request: &http.Request{URL: reqUrl},
+		}
+
+		buf, err := readBody(resp, test.max)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%s (max %d): expected error", test.encoding, test.max)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s (max %d): unexpected error: %s", test.encoding, test.max, err)
+			continue
+		}
+		if string(buf) != body {
+			t.Errorf("output `%v` != expected `%v`", string(buf), body)
+		}
+	}
+
+	// Make sure the error message is descriptive.
+	resp := &http.Response{
+		Body:    ioutil.NopCloser(strings.NewReader(body)),
+		Request: &http.Request{URL: reqUrl},
+	}
+	_, err = readBody(resp, 1)
+	if err == nil || !strings.Contains(err.Error(), "response too large") {
+		t.Errorf("expected `response too large` error; got %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package page
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/karlek/nyfiken/settings"
+)
+
+// certs returns the watched certificate lines of a response: the fingerprint
+// and issuer of the server certificate, the result of the validation and
+// whether a certificate of the chain expires within the expiry duration.
+func certs(r *response, expiry time.Duration) (s string) {
+	if len(r.Certs) == 0 {
+		return "Certificate: none" + settings.Newline
+	}
+	leaf := r.Certs[0]
+	sum := sha256.Sum256(leaf.Raw)
+	s += "Certificate: " + hex.EncodeToString(sum[:]) + settings.Newline
+	s += "Issuer: " + leaf.Issuer.String() + settings.Newline
+
+	verification := "ok"
+	if r.VerifyErr != "" {
+		verification = r.VerifyErr
+	}
+	s += "Verification: " + verification + settings.Newline
+
+	// The date rather than the remaining time is watched, so that the user is
+	// only notified once.
+	status := "ok"
+	if cert := expiring(r.Certs); cert != nil && time.Until(cert.NotAfter) < expiry {
+		status = cert.Subject.CommonName + " expires on " + cert.NotAfter.UTC().Format("2006-01-02")
+	}
+	s += "Expiry: " + status + settings.Newline
+	return s
+}
+
+// expiring returns the certificate of the chain which expires first.
+func expiring(chain []*x509.Certificate) (first *x509.Certificate) {
+	for _, cert := range chain {
+		if first == nil || cert.NotAfter.Before(first.NotAfter) {
+			first = cert
+		}
+	}
+	return first
+}
+
+// verifyError reports whether err is caused by a certificate which fails
+// validation.
+func verifyError(err error) bool {
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var invalidErr x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	return errors.As(err, &verifyErr) ||
+		errors.As(err, &authorityErr) ||
+		errors.As(err, &invalidErr) ||
+		errors.As(err, &hostnameErr)
+}
+
+// verifyFailure returns the certificate chain of the host of the page together
+// with the validation error, if its certificate fails validation. It returns
+// nil if the certificate is valid or can't be retrieved.
+func (p *Page) verifyFailure() *response {
+	addr := p.ReqUrl.Host
+	if p.ReqUrl.Port() == "" {
+		addr = net.JoinHostPort(p.ReqUrl.Hostname(), "443")
+	}
+	dialer := &net.Dialer{Timeout: settings.TimeoutDuration}
+
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, nil)
+	if err == nil {
+		conn.Close()
+		return nil
+	}
+	verifyErr := err.Error()
+
+	// Retrieve the chain without validation, to record the invalid certificate.
+	conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return nil
+	}
+	defer conn.Close()
+	return &response{
+		URL:       p.ReqUrl.String(),
+		Certs:     conn.ConnectionState().PeerCertificates,
+		VerifyErr: verifyErr,
+	}
+}
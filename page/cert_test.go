@@ -0,0 +1,84 @@
+package page
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/karlek/nyfiken/settings"
+)
+
+// Tests verifyFailure and certs
+func TestCerts(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Page{ReqUrl: u, Settings: settings.Page{Watch: []string{"status", "cert"}}}
+
+	// The certificate of the test server isn't signed by a trusted authority.
+	r := p.verifyFailure()
+	if r == nil {
+		t.Fatalf("output `%v` != expected validation failure", r)
+	}
+	if len(r.Certs) == 0 || r.VerifyErr == "" {
+		t.Errorf("output `%d certificates, %q` != expected chain and error", len(r.Certs), r.VerifyErr)
+	}
+
+	// The test certificate expires in the distant future.
+	s, err := p.watched(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Certificate: ", "Issuer: ", "unknown authority", "Expiry: ok"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("output `%v` doesn't contain expected `%v`", s, want)
+		}
+	}
+	// Only the certificate is watched since no response was received.
+	if strings.Contains(s, "Status: ") {
+		t.Errorf("output `%v` contains unexpected `%v`", s, "Status: ")
+	}
+
+	s = certs(r, time.Until(r.Certs[0].NotAfter)+time.Hour)
+	if !strings.Contains(s, "Expiry: ") || strings.Contains(s, "Expiry: ok") {
+		t.Errorf("output `%v` != expected upcoming expiry", s)
+	}
+}
+
+// Tests that download records the chain of servers failing validation, but only
+// for certificate errors
+func TestDownloadVerifyFailure(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Page{ReqUrl: u, Settings: settings.Page{Watch: []string{"cert"}}}
+	_, r, err := p.download()
+	if err == nil {
+		t.Fatal("expected error for untrusted certificate")
+	}
+	if r == nil || r.VerifyErr == "" {
+		t.Errorf("output `%v` != expected validation failure", r)
+	}
+
+	// Other download errors, e.g. a refused connection, aren't certificate
+	// failures.
+	ts.Close()
+	_, r, err = p.download()
+	if err == nil {
+		t.Fatal("expected error for closed server")
+	}
+	if r != nil {
+		t.Errorf("output `%v` != expected `%v`", r, nil)
+	}
+}
@@ -0,0 +1,32 @@
+package page
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/karlek/nyfiken/settings"
+	"github.com/mewkiz/pkg/errutil"
+	"golang.org/x/net/html/charset"
+)
+
+// UTF-8 encoded byte order mark.
+const bom = "\ufeff"
+
+// decode converts the downloaded page source to UTF-8. The character encoding
+// is determined as described in the HTML specification: by a byte order mark,
+// the charset parameter of the Content-Type header, a prescan of the <meta>
+// tags of the document or, as a last resort, by guessing.
+func decode(buf []byte, contentType string) (content []byte, err error) {
+	enc, name, certain := charset.DetermineEncoding(buf, contentType)
+	if settings.Verbose && !certain {
+		fmt.Println("[?] Guessed charset:", name)
+	}
+
+	content, err = enc.NewDecoder().Bytes(buf)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+
+	// Remove the byte order mark, which has been decoded to UTF-8.
+	return bytes.TrimPrefix(content, []byte(bom)), nil
+}
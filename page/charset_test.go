@@ -0,0 +1,40 @@
+package page
+
+import (
+	"testing"
+)
+
+// Tests decode
+func TestDecode(t *testing.T) {
+	var testTable = []struct {
+		buf         string
+		contentType string
+		out         string
+	}{
+		// UTF-8 without any declaration.
+		{"<p>blåbär</p>", "text/html", "<p>blåbär</p>"},
+		// Charset in header without a space.
+		{"<p>bl\xe5b\xe4r</p>", "text/html;charset=ISO-8859-1", "<p>blåbär</p>"},
+		// Quoted charset in header.
+		{"<p>bl\xe5b\xe4r</p>", `text/html; charset="windows-1252"`, "<p>blåbär</p>"},
+		// Meta charset.
+		{`<meta charset="iso-8859-1"><p>bl` + "\xe5b\xe4r</p>", "text/html", `<meta charset="iso-8859-1"><p>blåbär</p>`},
+		// Meta http-equiv.
+		{`<meta http-equiv="Content-Type" content="text/html; charset=koi8-r"><p>` + "\xd3\xd4\xcf\xcc</p>", "", `<meta http-equiv="Content-Type" content="text/html; charset=koi8-r"><p>стол</p>`},
+		// UTF-8 byte order mark overrides the header.
+		{"\xef\xbb\xbf<p>blåbär</p>", "text/html; charset=ISO-8859-1", "<p>blåbär</p>"},
+		// UTF-16 byte order mark.
+		{"\xff\xfe<\x00p\x00>\x00\xe5\x00<\x00/\x00p\x00>\x00", "", "<p>å</p>"},
+	}
+
+	for _, test := range testTable {
+		content, err := decode([]byte(test.buf), test.contentType)
+		if err != nil {
+			t.Errorf("error: %s", err)
+			continue
+		}
+		if string(content) != test.out {
+			t.Errorf("output `%v` != expected `%v`", string(content), test.out)
+		}
+	}
+}
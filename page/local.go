@@ -0,0 +1,88 @@
+package page
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/karlek/nyfiken/filter"
+	"github.com/mewkiz/pkg/errutil"
+	"golang.org/x/net/html"
+)
+
+// Schemes of pages which aren't requested over HTTP.
+const (
+	// SchemeFile reads a local file, e.g. `file:///var/www/report.html`.
+	SchemeFile = "file"
+	// SchemeExec runs a command and reads its output, e.g. `exec:df -h`.
+	SchemeExec = "exec"
+)
+
+// IsLocal reports whether the page is a local file or command, rather than a
+// site.
+func (p *Page) IsLocal() bool {
+	return p.ReqUrl.Scheme == SchemeFile || p.ReqUrl.Scheme == SchemeExec
+}
+
+// Command returns the command of an exec page.
+func (p *Page) Command() (cmd *filter.Cmd, err error) {
+	return filter.Parse(filter.TypeCmd + filter.TypeSep + p.ReqUrl.Opaque)
+}
+
+// local reads the contents of a local file or the output of a command, which
+// are checked like the source of a site.
+func (p *Page) local() (doc *html.Node, err error) {
+	var buf []byte
+	switch p.ReqUrl.Scheme {
+	case SchemeFile:
+		buf, err = readFile(p.ReqUrl.Path, p.Settings.MaxBodySize)
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+	case SchemeExec:
+		cmd, err := p.Command()
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+		buf, err = cmd.Output("", p.Settings.FilterTimeout, p.Settings.MaxBodySize)
+		if err != nil {
+			return nil, errutil.Err(err)
+		}
+	}
+
+	max := p.Settings.MaxBodySize
+	if max > 0 && int64(len(buf)) > max {
+		return nil, errutil.NewNoPosf(errTooLarge, p.ReqUrl, max)
+	}
+
+	// Local files have no Content-Type header, so the charset is determined by
+	// the content.
+	content, err := decode(buf, "")
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+
+	return html.Parse(bytes.NewReader(content))
+}
+
+// readFile reads a local file. Files larger than max bytes are only read up to
+// one byte more than allowed, so that the size check doesn't require reading
+// them into memory; 0 means no limit.
+func readFile(path string, max int64) (buf []byte, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if max > 0 {
+		r = io.LimitReader(f, max+1)
+	}
+	buf, err = ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	return buf, nil
+}
@@ -0,0 +1,79 @@
+package page
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/karlek/nyfiken/settings"
+	"github.com/mewkiz/pkg/htmlutil"
+)
+
+// Tests local
+func TestLocal(t *testing.T) {
+	f, err := ioutil.TempFile("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.WriteString("<p>Disk usage: 42%</p>"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	var testTable = []struct {
+		u        *url.URL
+		max      int64
+		expected string
+		valid    bool
+	}{
+		{&url.URL{Scheme: SchemeFile, Path: f.Name()}, 0, "<html><head></head><body><p>Disk usage: 42%</p></body></html>", true},
+		{&url.URL{Scheme: SchemeFile, Path: f.Name()}, 10, "", false},
+		{&url.URL{Scheme: SchemeFile, Path: f.Name()}, 22, "<html><head></head><body><p>Disk usage: 42%</p></body></html>", true},
+		{&url.URL{Scheme: SchemeFile, Path: f.Name() + ".missing"}, 0, "", false},
+		{&url.URL{Scheme: SchemeExec, Opaque: "echo 'Build passed'"}, 0, "<html><head></head><body>Build passed\n</body></html>", true},
+		{&url.URL{Scheme: SchemeExec, Opaque: "false"}, 0, "", false},
+		{&url.URL{Scheme: SchemeExec, Opaque: "yes"}, 10, "", false},
+	}
+	for _, test := range testTable {
+		p := &Page{ReqUrl: test.u, Settings: settings.Page{MaxBodySize: test.max, FilterTimeout: time.Second}}
+		doc, err := p.local()
+		if (err == nil) != test.valid {
+			t.Errorf("%s: output valid `%v` != expected `%v`; %v", test.u, err == nil, test.valid, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		output, err := htmlutil.RenderClean(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if output != test.expected {
+			t.Errorf("output `%v` != expected `%v`", output, test.expected)
+		}
+	}
+}
+
+// Tests that readFile reads at most one byte more than allowed
+func TestReadFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err = f.WriteString("<p>Disk usage: 42%</p>"); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	buf, err := readFile(f.Name(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(buf) != 11 {
+		t.Errorf("output `%v` != expected `%v`", len(buf), 11)
+	}
+}
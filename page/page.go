@@ -2,6 +2,9 @@
 package page
 
 import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -14,10 +17,13 @@ import (
 	"time"
 
 	"github.com/andybalholm/cascadia"
-	"github.com/axgle/mahonia"
+	"github.com/karlek/nyfiken/diff"
 	"github.com/karlek/nyfiken/distance"
 	"github.com/karlek/nyfiken/filename"
-	"github.com/karlek/nyfiken/mail"
+	"github.com/karlek/nyfiken/filter"
+	"github.com/karlek/nyfiken/jar"
+	"github.com/karlek/nyfiken/login"
+	"github.com/karlek/nyfiken/notify"
 	"github.com/karlek/nyfiken/settings"
 	"github.com/karlek/nyfiken/strip"
 	"github.com/mewkiz/pkg/errutil"
@@ -25,6 +31,14 @@ import (
 	"golang.org/x/net/html"
 )
 
+// Maximum number of changed lines in the diff excerpt of notifications.
+const maxDiffLines = 20
+
+// Error messages.
+var (
+	errStillLogin = "page: %s: still redirected to the login page %s after logging in."
+)
+
 // Page is a site which is checked for changes. It has specialized settings to
 // eliminate false-positives.
 type Page struct {
@@ -33,14 +47,38 @@ type Page struct {
 }
 
 func (p *Page) UrlAsFilename() string {
-	return p.ReqUrl.Host + p.ReqUrl.Path + p.ReqUrl.RawQuery
+	var name string
+	switch p.ReqUrl.Scheme {
+	case SchemeFile:
+		name = SchemeFile + ":" + p.ReqUrl.Path
+	case SchemeExec:
+		name = SchemeExec + ":" + p.ReqUrl.Opaque
+	default:
+		name = p.ReqUrl.Host + p.ReqUrl.Path + p.ReqUrl.RawQuery
+	}
+
+	// Requests to the same URL with different methods or bodies are cached
+	// separately.
+	if (p.Settings.Method != "" && p.Settings.Method != "GET") || p.Settings.Body != "" {
+		sum := sha1.Sum([]byte(p.Settings.Method + " " + p.Settings.Body))
+		name += "#" + hex.EncodeToString(sum[:8])
+	}
+	return name
 }
 
 // Check downloads and makes a specialized comparison with a previous check
 // saved on disk to determine if the page has been updated. Check takes
 // an error channel to concurrently handle errors.
 func (p *Page) Check(ch chan<- error) {
-	ch <- p.check()
+	err := p.check()
+
+	// Keep track of failing checks to inform the user.
+	if err != nil {
+		settings.SetError(p.ReqUrl.String(), err)
+	} else {
+		settings.ClearError(p.ReqUrl.String())
+	}
+	ch <- err
 }
 
 // check is an non-exported function for better error handling.
@@ -52,10 +90,19 @@ func (p *Page) check() (err error) {
 	// Retrieve result from download or return timeout error.
 	var r struct {
 		*html.Node
+		*response
 		error
 	}
 	select {
 	case r = <-errWrapDownload(p):
+		// Watch the response even if the request failed, e.g. with a client
+		// or server error.
+		if r.response != nil {
+			err = p.checkResponse(r.response)
+			if err != nil {
+				return errutil.Err(err)
+			}
+		}
 		if r.error != nil {
 			return errutil.Err(r.error)
 		}
@@ -144,39 +191,51 @@ func (p *Page) check() (err error) {
 	// If the distance is within the threshold level, i.e if the check was a
 	// match.
 	if dist > p.Settings.Threshold {
-		u := p.ReqUrl.String()
-		settings.Updates[u] = true
-
 		if settings.Verbose {
 			fmt.Println("[!] Updated:", p.ReqUrl.String())
 		}
 
-		// If the page has a mail and all compulsory global mail settings are
-		// set, send a mail to notify the user about an update.
-		if p.Settings.RecvMail != "" &&
-			settings.Global.SenderMail.AuthServer != "" &&
-			settings.Global.SenderMail.OutServer != "" &&
-			settings.Global.SenderMail.Address != "" {
+		// Save snapshots of the previous and current check for notifiers.
+		_, oldPath, err := saveSnapshot(string(buf))
+		if err != nil {
+			return errutil.Err(err)
+		}
+		id, newPath, err := saveSnapshot(selection)
+		if err != nil {
+			return errutil.Err(err)
+		}
+		err = pruneSnapshots()
+		if err != nil {
+			return errutil.Err(err)
+		}
 
+		// The differences are computed once, and rendered by each notifier.
+		lines := diff.Lines(string(buf), selection)
+		e := &notify.Event{
+			Title:    title(r.Node),
+			Score:    dist,
+			Diff:     diff.Excerpt(lines, maxDiffLines),
+			Lines:    lines,
+			Snapshot: id,
+			OldPath:  oldPath,
+			NewPath:  newPath,
+		}
+		err = p.update(e, func() (string, error) {
 			// Mail the selection without the stripping functions, since their
 			// only purpose is to remove false-positives. It will make the
 			// output look better.
 			mailPage := Page{p.ReqUrl, p.Settings}
 			mailPage.Settings.StripFuncs = nil
-			mailPage.Settings.Regexp = ""
-			sel, err := mailPage.makeSelection(r.Node)
-			if err != nil {
-				return errutil.Err(err)
+			// Keep the reader extraction though, since it removes the clutter
+			// surrounding the main content.
+			for _, stripFunc := range p.Settings.StripFuncs {
+				if name, _ := strip.Split(stripFunc); name == "reader" {
+					mailPage.Settings.StripFuncs = []string{stripFunc}
+				}
 			}
-
-			err = mail.Send(p.ReqUrl, p.Settings.RecvMail, sel)
-			if err != nil {
-				return errutil.Err(err)
-			}
-			delete(settings.Updates, u)
-		}
-		// Save updates to file.
-		err = settings.SaveUpdates()
+			mailPage.Settings.Regexp = ""
+			return mailPage.makeSelection(r.Node)
+		})
 		if err != nil {
 			return errutil.Err(err)
 		}
@@ -194,84 +253,263 @@ func (p *Page) check() (err error) {
 	return nil
 }
 
+// update records that the page has been updated and posts notifications about
+// the event e to the outbox, which delivers them to the notifiers of the page.
+// The body of the event is returned by body, which is only called if the page
+// has notifiers. Updates delivered by a consuming notifier, e.g. mail, are
+// removed from the list of unread updates once delivered.
+func (p *Page) update(e *notify.Event, body func() (string, error)) (err error) {
+	u := p.ReqUrl.String()
+	err = settings.SetUpdated(u)
+	if err != nil {
+		return errutil.Err(err)
+	}
+
+	names, mail, err := p.notifiers()
+	if err != nil {
+		return errutil.Err(err)
+	}
+	if len(names) == 0 && !mail {
+		return nil
+	}
+	e.URL = p.ReqUrl
+	e.Time = time.Now()
+	if e.Title == "" {
+		e.Title = u
+	}
+	e.Body, err = body()
+	if err != nil {
+		return errutil.Err(err)
+	}
+	for _, name := range names {
+		err = notify.Post(name, e)
+		if err != nil {
+			return errutil.Err(err)
+		}
+	}
+	if mail {
+		err = notify.PostMail(p.Settings.RecvMail, p.Settings.CC, e)
+		if err != nil {
+			return errutil.Err(err)
+		}
+	}
+	notify.Wake()
+	return nil
+}
+
+// notifiers returns the names of the notifiers of the page, and whether the
+// page is mailed without a named notifier. Pages without notifiers are mailed
+// to their receiving mail address, if all compulsory global mail settings are
+// set.
+func (p *Page) notifiers() (names []string, mail bool, err error) {
+	for _, name := range p.Settings.Notify {
+		if _, err = notify.Lookup(name); err != nil {
+			return nil, false, errutil.Err(err)
+		}
+	}
+	if len(p.Settings.Notify) == 0 && len(p.Settings.RecvMail) > 0 {
+		_, err = notify.New(notify.MailConf(p.Settings.RecvMail, p.Settings.CC))
+		mail = err == nil
+	}
+	return p.Settings.Notify, mail, nil
+}
+
+// title returns the title of an HTML document.
+func title(doc *html.Node) string {
+	t := cascadia.MustCompile("title").MatchFirst(doc)
+	if t == nil || t.FirstChild == nil {
+		return ""
+	}
+	return strings.TrimSpace(t.FirstChild.Data)
+}
+
 // An error wrapping convenience function for p.download() used because of
 // timeout implementation.
 // Credits to: Dave Cheney and ilyia (https://groups.google.com/forum/?fromgroups=#!topic/golang-nuts/cTrBcyjqCxg)
 func errWrapDownload(p *Page) <-chan struct {
 	*html.Node
+	*response
 	error
 } {
-	doc, err := p.download()
+	doc, resp, err := p.download()
 	result := make(chan struct {
 		*html.Node
+		*response
 		error
 	})
 	go func() {
 		result <- struct {
 			*html.Node
+			*response
 			error
-		}{doc, err}
+		}{doc, resp, err}
 	}()
 	return result
 }
 
 // Download the page with or without user specified headers.
-func (p *Page) download() (doc *html.Node, err error) {
+// The status, final URL and header of the response are returned even if the
+// response contained a client or server error, so that they can be watched.
+func (p *Page) download() (doc *html.Node, r *response, err error) {
+	// Local files and commands have no response to watch.
+	if p.IsLocal() {
+		doc, err = p.local()
+		if err != nil {
+			return nil, nil, errutil.Err(err)
+		}
+		return doc, nil, nil
+	}
 
-	// Construct the request.
-	req, err := http.NewRequest("GET", p.ReqUrl.String(), nil)
+	client, err := p.client()
 	if err != nil {
-		return nil, errutil.Err(err)
+		return nil, nil, errutil.Err(err)
 	}
 
-	// If special headers were specified, add them to the request.
-	if p.Settings.Header != nil {
-		for key, val := range p.Settings.Header {
-			req.Header.Add(key, val)
+	// Do request and read response.
+	resp, err := p.do(client)
+	if err != nil {
+		// Record the certificate chain of servers failing validation, so that
+		// the failure can be watched.
+		if p.watches(WatchCert) && verifyError(err) {
+			return nil, p.verifyFailure(), errutil.Err(err)
 		}
+		return nil, nil, errutil.Err(err)
 	}
 
-	// Do request and read response.
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		if serr, ok := err.(*url.Error); ok {
-			if serr.Err == io.EOF {
-				return nil, errutil.NewNoPosf("Update was empty: %s", p.ReqUrl)
-			}
+	// If the session has expired, log in again and retry the request.
+	if p.Settings.Login != nil && login.Required(resp, p.Settings.Login) {
+		resp.Body.Close()
+		err = login.Do(client, p.Settings.Login)
+		if err != nil {
+			return nil, nil, errutil.Err(err)
+		}
+		resp, err = p.do(client)
+		if err != nil {
+			return nil, nil, errutil.Err(err)
+		}
+		// Don't check the login page instead of the page.
+		if login.Required(resp, p.Settings.Login) {
+			resp.Body.Close()
+			return nil, nil, errutil.NewNoPosf(errStillLogin, p.ReqUrl, resp.Request.URL)
 		}
-		return nil, errutil.Err(err)
 	}
 	defer resp.Body.Close()
 
+	// Persist the session cookies for the next check.
+	if j, ok := client.Jar.(*jar.Jar); ok {
+		err = j.Save()
+		if err != nil {
+			return nil, nil, errutil.Err(err)
+		}
+	}
+
+	r = &response{
+		Status: resp.StatusCode,
+		URL:    resp.Request.URL.String(),
+		Header: resp.Header,
+	}
+	if resp.TLS != nil {
+		r.Certs = resp.TLS.PeerCertificates
+	}
+
 	// If response contained a client or server error, fail with that error.
 	if resp.StatusCode >= 400 {
-		return nil, errutil.Newf("%s: (%d) - %s", p.ReqUrl.String(), resp.StatusCode, resp.Status)
+		return nil, r, errutil.Newf("%s: (%d) - %s", p.ReqUrl.String(), resp.StatusCode, resp.Status)
+	}
+
+	// Read the decompressed response body to []byte.
+	buf, err := readBody(resp, p.Settings.MaxBodySize)
+	if err != nil {
+		return nil, r, errutil.Err(err)
+	}
+
+	// Fix charset problems with servers that doesn't use utf-8.
+	content, err := decode(buf, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, r, errutil.Err(err)
+	}
+
+	// Parse response into html.Node.
+	doc, err = html.Parse(bytes.NewReader(content))
+	if err != nil {
+		return nil, r, errutil.Err(err)
 	}
+	return doc, r, nil
+}
 
-	// Read the response body to []byte.
-	buf, err := ioutil.ReadAll(resp.Body)
+// client returns the HTTP client to check the page with. Pages with a cookie
+// jar keep their cookies in it, otherwise pages using a login recipe keep their
+// session cookies in the cookie jar of the recipe.
+func (p *Page) client() (client *http.Client, err error) {
+	var name string
+	switch {
+	case p.Settings.CookieJar != "":
+		name = p.Settings.CookieJar
+	case p.Settings.Login != nil:
+		name = p.Settings.Login.Name
+	default:
+		return http.DefaultClient, nil
+	}
+	j, err := jar.Get(name)
 	if err != nil {
 		return nil, errutil.Err(err)
 	}
+	return &http.Client{Jar: j}, nil
+}
 
-	// Fix charset problems with servers that doesn't use utf-8
-	charset := "utf-8"
-	content := string(buf)
+// do constructs and sends the request of the page.
+func (p *Page) do(client *http.Client) (resp *http.Response, err error) {
+	// Construct the request.
+	method := p.Settings.Method
+	if method == "" {
+		method = "GET"
+	}
+	var body io.Reader
+	if p.Settings.Body != "" {
+		body = strings.NewReader(p.Settings.Body)
+	}
+	req, err := http.NewRequest(method, p.ReqUrl.String(), body)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
 
-	types := strings.Split(resp.Header.Get("Content-Type"), ` `)
-	for _, typ := range types {
-		if strings.Contains(typ, "charset") {
-			keyval := strings.Split(typ, `=`)
-			if len(keyval) == 2 {
-				charset = keyval[1]
-			}
+	// If special headers were specified, add them to the request.
+	if p.Settings.Header != nil {
+		for key, val := range p.Settings.Header {
+			req.Header.Add(key, val)
+		}
+	}
+
+	// Authenticate using the credentials of the page.
+	if a := p.Settings.Auth; a != nil {
+		switch a.Type {
+		case settings.AuthBasic:
+			req.SetBasicAuth(a.User, a.Password)
+		case settings.AuthBearer:
+			req.Header.Set("Authorization", "Bearer "+a.Password)
 		}
 	}
-	if charset != "utf-8" {
-		content = mahonia.NewDecoder(charset).ConvertString(content)
+
+	// Ask for compressed responses, unless the user specified the encodings.
+	if req.Header.Get("Accept-Encoding") == "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
 	}
-	// Parse response into html.Node.
-	return html.Parse(strings.NewReader(content))
+
+	resp, err = client.Do(req)
+	if err != nil {
+		if serr, ok := err.(*url.Error); ok {
+			if serr.Err == io.EOF {
+				return nil, errutil.NewNoPosf("Update was empty: %s", p.ReqUrl)
+			}
+		}
+		// Certificate validation failures are returned as is, so that they
+		// can be detected by verifyError.
+		if verifyError(err) {
+			return nil, err
+		}
+		return nil, errutil.Err(err)
+	}
+	return resp, nil
 }
 
 // Select from the retrived page source the CSS selection defined in c4c.ini.
@@ -315,17 +553,11 @@ func (p *Page) makeSelection(htmlNode *html.Node) (selection string, err error)
 		if err != nil {
 			return "", errutil.Err(err)
 		}
-		stripFunc = strings.ToLower(stripFunc)
-		switch stripFunc {
-		case "numbers":
-			strip.Numbers(doc)
-		case "attrs":
-			strip.Attrs(doc)
-		case "html":
-			strip.HTML(doc)
-		case "scripts":
-			strip.Scripts(doc)
+		f, err := strip.Parse(stripFunc)
+		if err != nil {
+			return "", errutil.Err(err)
 		}
+		f(doc)
 
 		selection, err = htmlutil.RenderClean(doc)
 		if err != nil {
@@ -335,6 +567,23 @@ func (p *Page) makeSelection(htmlNode *html.Node) (selection string, err error)
 
 	// --- [ /Strip funcs ] ---------------------------------------------------/
 
+	// --- [ Filters ] --------------------------------------------------------/
+
+	for _, decl := range p.Settings.Filters {
+		cmd, err := filter.Parse(decl)
+		if err != nil {
+			return "", errutil.Err(err)
+		}
+
+		// The output of the command is the new selection.
+		selection, err = cmd.Run(selection, p.Settings.FilterTimeout)
+		if err != nil {
+			return "", errutil.Err(err)
+		}
+	}
+
+	// --- [ /Filters ] -------------------------------------------------------/
+
 	// --- [ Regexp ] ---------------------------------------------------------/
 
 	if p.Settings.Regexp != "" {
@@ -0,0 +1,138 @@
+package page
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/karlek/nyfiken/settings"
+)
+
+// Tests that download fails if the page is still redirected to the login page
+// after logging in
+func TestDownloadStillLogin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nyfiken-jar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	jarRoot := settings.JarRoot
+	settings.JarRoot = dir + "/"
+	defer func() { settings.JarRoot = jarRoot }()
+
+	// The login succeeds, but the session isn't accepted by the page.
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			http.Redirect(w, r, "/home", http.StatusFound)
+			return
+		}
+		fmt.Fprint(w, `<form method="post" action="/login"><input type="password" name="pass"></form>`)
+	})
+	mux.HandleFunc("/home", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "home")
+	})
+	mux.HandleFunc("/secret", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL + "/secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := &settings.Login{Name: "still-login", URL: ts.URL + "/login", Fields: map[string]string{"pass": "secret"}}
+	p := &Page{ReqUrl: u, Settings: settings.Page{Login: l}}
+	_, _, err = p.download()
+	if err == nil || !strings.Contains(err.Error(), "still redirected") {
+		t.Errorf("output `%v` != expected `%v`", err, "still redirected to the login page")
+	}
+}
+
+// Tests that the configured method, body and headers reach the request
+func TestDo(t *testing.T) {
+	var method, body, contentType, token string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		method, body = r.Method, string(buf)
+		contentType, token = r.Header.Get("Content-Type"), r.Header.Get("X-Token")
+	}))
+	defer ts.Close()
+
+	u, err := url.Parse(ts.URL + "/search")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var testTable = []struct {
+		method   string
+		body     string
+		header   map[string]string
+		expected string
+	}{
+		{"", "", nil, "GET"},
+		{"POST", "q=nyfiken", map[string]string{"Content-Type": "application/x-www-form-urlencoded", "X-Token": "42"}, "POST"},
+		{"PUT", `{"q": "nyfiken"}`, map[string]string{"Content-Type": "application/json"}, "PUT"},
+	}
+	for _, test := range testTable {
+		p := &Page{ReqUrl: u, Settings: settings.Page{Method: test.method, Body: test.body, Header: test.header}}
+		resp, err := p.do(http.DefaultClient)
+		if err != nil {
+			t.Errorf("%s: %v", test.expected, err)
+			continue
+		}
+		resp.Body.Close()
+		if method != test.expected {
+			t.Errorf("output `%v` != expected `%v`", method, test.expected)
+		}
+		if body != test.body {
+			t.Errorf("output `%v` != expected `%v`", body, test.body)
+		}
+		if contentType != test.header["Content-Type"] || token != test.header["X-Token"] {
+			t.Errorf("output `%v, %v` != expected `%v`", contentType, token, test.header)
+		}
+	}
+}
+
+// Tests UrlAsFilename
+func TestUrlAsFilename(t *testing.T) {
+	u, err := url.Parse("http://example.org/search?q=nyfiken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := func(method, body string) string {
+		p := &Page{ReqUrl: u, Settings: settings.Page{Method: method, Body: body}}
+		return p.UrlAsFilename()
+	}
+
+	// Plain GET requests keep the cache names of previous versions.
+	if output, expected := name("", ""), "example.org/searchq=nyfiken"; output != expected {
+		t.Errorf("output `%v` != expected `%v`", output, expected)
+	}
+	if output, expected := name("GET", ""), name("", ""); output != expected {
+		t.Errorf("output `%v` != expected `%v`", output, expected)
+	}
+
+	// Requests with different methods or bodies have different cache names.
+	names := map[string]bool{name("", ""): true}
+	for _, test := range [][2]string{
+		{"POST", ""},
+		{"POST", "q=a"},
+		{"POST", "q=b"},
+		{"PUT", "q=a"},
+	} {
+		output := name(test[0], test[1])
+		if names[output] {
+			t.Errorf("%s %s: duplicate cache name `%v`", test[0], test[1], output)
+		}
+		names[output] = true
+	}
+}
@@ -0,0 +1,47 @@
+package page
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/karlek/nyfiken/settings"
+	"github.com/mewkiz/pkg/errutil"
+)
+
+// saveSnapshot saves the selection of a check, so that it can be handed to
+// notifiers. Snapshots are named by the hash of their contents, which is
+// returned as their ID.
+func saveSnapshot(selection string) (id, path string, err error) {
+	sum := sha1.Sum([]byte(selection))
+	id = hex.EncodeToString(sum[:])
+	path = settings.SnapshotRoot + id + ".htm"
+
+	// Rewrite existing snapshots too, to postpone their removal.
+	err = ioutil.WriteFile(path, []byte(selection), settings.Global.FilePerms)
+	if err != nil {
+		return "", "", errutil.Err(err)
+	}
+	return id, path, nil
+}
+
+// pruneSnapshots removes snapshots which haven't been saved for the maximum
+// age of snapshots.
+func pruneSnapshots() (err error) {
+	fis, err := ioutil.ReadDir(settings.SnapshotRoot)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	for _, fi := range fis {
+		if time.Since(fi.ModTime()) < settings.SnapshotMaxAge {
+			continue
+		}
+		err = os.Remove(settings.SnapshotRoot + fi.Name())
+		if err != nil && !os.IsNotExist(err) {
+			return errutil.Err(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package page
+
+import (
+	"crypto/x509"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/karlek/nyfiken/filename"
+	"github.com/karlek/nyfiken/notify"
+	"github.com/karlek/nyfiken/settings"
+	"github.com/mewkiz/pkg/errutil"
+)
+
+// Parts of a response which may be watched for changes, in addition to the
+// selection.
+const (
+	// WatchStatus watches the status code, e.g. a page starting to return 404.
+	WatchStatus = "status"
+	// WatchURL watches the final URL after redirects.
+	WatchURL = "url"
+	// WatchHeader watches a response header, e.g. `header:Last-Modified`.
+	WatchHeader = "header"
+	// WatchCert watches the TLS certificate of the server for changes,
+	// validation failures and upcoming expiry.
+	WatchCert = "cert"
+)
+
+// WatchSep separates the type of a watch from its argument.
+const WatchSep = ":"
+
+// Error messages.
+var (
+	errInvalidWatch = "page: invalid watch: `%s`; correct syntax -> `" + WatchStatus + "`, `" + WatchURL + "`, `" + WatchCert + "` or `" + WatchHeader + WatchSep + "Name`."
+)
+
+// A response contains the parts of an HTTP response which may be watched.
+type response struct {
+	Status int         // Status code of the response; 0 if no response was received.
+	URL    string      // Final URL of the request, after redirects.
+	Header http.Header // Header of the response.
+
+	Certs     []*x509.Certificate // Certificate chain presented by the server.
+	VerifyErr string              // Reason the certificate chain failed validation.
+}
+
+// ParseWatch parses a watch declaration (e.g. `header:Last-Modified`) and
+// returns its type and, for headers, the canonical header name.
+func ParseWatch(decl string) (typ, name string, err error) {
+	typ = strings.ToLower(strings.TrimSpace(decl))
+	if i := strings.Index(typ, WatchSep); i != -1 {
+		typ = typ[:i]
+		name = strings.TrimSpace(decl[i+len(WatchSep):])
+	}
+	switch {
+	case (typ == WatchStatus || typ == WatchURL || typ == WatchCert) && name == "":
+		return typ, "", nil
+	case typ == WatchHeader && name != "":
+		return typ, http.CanonicalHeaderKey(name), nil
+	}
+	return "", "", errutil.NewNoPosf(errInvalidWatch, decl)
+}
+
+// watched returns the watched parts of the response, one per line. Only the
+// certificate is watched if the request failed before a response was received.
+func (p *Page) watched(r *response) (s string, err error) {
+	for _, decl := range p.Settings.Watch {
+		typ, name, err := ParseWatch(decl)
+		if err != nil {
+			return "", errutil.Err(err)
+		}
+		if r.Status == 0 && typ != WatchCert {
+			continue
+		}
+		switch typ {
+		case WatchStatus:
+			s += "Status: " + strconv.Itoa(r.Status) + settings.Newline
+		case WatchURL:
+			s += "URL: " + r.URL + settings.Newline
+		case WatchHeader:
+			s += name + ": " + strings.Join(r.Header[name], ", ") + settings.Newline
+		case WatchCert:
+			s += certs(r, p.Settings.CertExpiry)
+		}
+	}
+	return s, nil
+}
+
+// checkResponse compares the watched parts of the response with the previous
+// check and notifies the user if they have changed.
+func (p *Page) checkResponse(r *response) (err error) {
+	if len(p.Settings.Watch) == 0 {
+		return nil
+	}
+	watched, err := p.watched(r)
+	if err != nil {
+		return errutil.Err(err)
+	}
+
+	linuxPath, err := filename.Encode(p.UrlAsFilename())
+	if err != nil {
+		return errutil.Err(err)
+	}
+	cachePathName := settings.CacheRoot + linuxPath + ".watch"
+
+	buf, err := ioutil.ReadFile(cachePathName)
+	if err != nil && !os.IsNotExist(err) {
+		return errutil.Err(err)
+	}
+	prev := string(buf)
+	if watched == prev {
+		return nil
+	}
+
+	// Parts which weren't watched in the previous check, e.g. if the page has
+	// never been checked before, have nothing to be compared to.
+	diff := watchDiff(prev, watched)
+	if diff != "" {
+		if settings.Verbose {
+			fmt.Println("[!] Response changed:", p.ReqUrl.String())
+		}
+		err = p.update(&notify.Event{Diff: diff}, func() (string, error) {
+			return "<pre>" + html.EscapeString(diff) + "</pre>", nil
+		})
+		if err != nil {
+			return errutil.Err(err)
+		}
+	}
+
+	// Update the comparison file once the change has been recorded, so that
+	// it's detected again by the next check otherwise.
+	err = ioutil.WriteFile(cachePathName, []byte(watched), settings.Global.FilePerms)
+	if err != nil {
+		return errutil.Err(err)
+	}
+	return nil
+}
+
+// watchDiff returns the watched parts which have changed, as `- old` and `+ new`
+// lines. Parts which are missing from prev are ignored, unless they report a
+// certificate failure, e.g. a certificate which already fails validation at the
+// first check.
+func watchDiff(prev, cur string) (diff string) {
+	old := make(map[string]string)
+	for _, line := range strings.Split(prev, settings.Newline) {
+		old[watchKey(line)] = line
+	}
+	for _, line := range strings.Split(cur, settings.Newline) {
+		prevLine, found := old[watchKey(line)]
+		if !found {
+			if certFailure(line) {
+				diff += "+ " + line + settings.Newline
+			}
+			continue
+		}
+		if prevLine == line {
+			continue
+		}
+		diff += "- " + prevLine + settings.Newline
+		diff += "+ " + line + settings.Newline
+	}
+	return diff
+}
+
+// certFailure reports whether a watched line reports that the certificate fails
+// validation or expires soon.
+func certFailure(line string) bool {
+	key := watchKey(line)
+	if key != "Verification" && key != "Expiry" {
+		return false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(line, key+":")) != "ok"
+}
+
+// watchKey returns the name of a watched part, e.g. `Status`.
+func watchKey(line string) string {
+	return strings.SplitN(line, ":", 2)[0]
+}
+
+// watches reports whether the page watches the part typ of the response.
+func (p *Page) watches(typ string) bool {
+	for _, decl := range p.Settings.Watch {
+		if t, _, _ := ParseWatch(decl); t == typ {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package page
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/karlek/nyfiken/filename"
+	"github.com/karlek/nyfiken/settings"
+)
+
+// Tests ParseWatch
+func TestParseWatch(t *testing.T) {
+	var testTable = []struct {
+		decl  string
+		typ   string
+		name  string
+		valid bool
+	}{
+		{"status", WatchStatus, "", true},
+		{"URL", WatchURL, "", true},
+		{"header:last-modified", WatchHeader, "Last-Modified", true},
+		{"header: X-Version", WatchHeader, "X-Version", true},
+		{"header", "", "", false},
+		{"status:200", "", "", false},
+		{"body", "", "", false},
+	}
+	for _, test := range testTable {
+		typ, name, err := ParseWatch(test.decl)
+		if (err == nil) != test.valid {
+			t.Errorf("%s: output valid `%v` != expected `%v`", test.decl, err == nil, test.valid)
+			continue
+		}
+		if typ != test.typ || name != test.name {
+			t.Errorf("output `%v:%v` != expected `%v:%v`", typ, name, test.typ, test.name)
+		}
+	}
+}
+
+// Tests watched and watchDiff
+func TestWatchDiff(t *testing.T) {
+	p := &Page{Settings: settings.Page{Watch: []string{"status", "url", "header:X-Version"}}}
+	r := &response{
+		Status: 200,
+		URL:    "http://example.org/",
+		Header: http.Header{"X-Version": {"1.0"}},
+	}
+	prev, err := p.watched(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var testTable = []struct {
+		status  int
+		version string
+		diff    string
+	}{
+		{200, "1.0", ""},
+		{404, "1.0", "- Status: 200\n+ Status: 404\n"},
+		{200, "1.1", "- X-Version: 1.0\n+ X-Version: 1.1\n"},
+	}
+	for _, test := range testTable {
+		r.Status = test.status
+		r.Header.Set("X-Version", test.version)
+		cur, err := p.watched(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := watchDiff(prev, cur); diff != test.diff {
+			t.Errorf("output `%v` != expected `%v`", diff, test.diff)
+		}
+	}
+
+	// Newly watched parts have nothing to be compared to.
+	if diff := watchDiff("", prev); diff != "" {
+		t.Errorf("output `%v` != expected `%v`", diff, "")
+	}
+
+	// Except certificates which already fail at the first check.
+	cur := "Certificate: abc\nVerification: x509: certificate has expired\nExpiry: ok\n"
+	if diff, expected := watchDiff("", cur), "+ Verification: x509: certificate has expired\n"; diff != expected {
+		t.Errorf("output `%v` != expected `%v`", diff, expected)
+	}
+	cur = "Certificate: abc\nVerification: ok\nExpiry: example.org expires on 2014-03-01\n"
+	if diff, expected := watchDiff("", cur), "+ Expiry: example.org expires on 2014-03-01\n"; diff != expected {
+		t.Errorf("output `%v` != expected `%v`", diff, expected)
+	}
+}
+
+// Tests that checkResponse only reports changed responses, and keeps the
+// previous response until the change has been recorded.
+func TestCheckResponse(t *testing.T) {
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cacheRoot, updatesPath := settings.CacheRoot, settings.UpdatesPath
+	settings.CacheRoot = dir + "/"
+	defer func() { settings.CacheRoot, settings.UpdatesPath = cacheRoot, updatesPath }()
+
+	u, _ := url.Parse("http://example.org/")
+	p := &Page{ReqUrl: u, Settings: settings.Page{Watch: []string{WatchStatus}}}
+	linuxPath, err := filename.Encode(p.UrlAsFilename())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var testTable = []struct {
+		status   int
+		fail     bool
+		updated  bool
+		expected string
+	}{
+		// First check; nothing to compare to.
+		{200, false, false, "Status: 200" + settings.Newline},
+		// Unchanged response.
+		{200, false, false, "Status: 200" + settings.Newline},
+		// Changed response which fails to be recorded.
+		{404, true, false, "Status: 200" + settings.Newline},
+		// Changed response.
+		{404, false, true, "Status: 404" + settings.Newline},
+	}
+	for i, test := range testTable {
+		settings.UpdatesPath = dir + "/updates.gob"
+		if test.fail {
+			settings.UpdatesPath = dir + "/missing/updates.gob"
+		}
+		if err = settings.ClearUpdates(); err != nil && !test.fail {
+			t.Fatal(err)
+		}
+		err = p.checkResponse(&response{Status: test.status})
+		if (err != nil) != test.fail {
+			t.Errorf("%d: output failed `%v` != expected `%v`; %v", i, err != nil, test.fail, err)
+		}
+		if updated := settings.CopyUpdates()[u.String()]; !test.fail && updated != test.updated {
+			t.Errorf("%d: output updated `%v` != expected `%v`", i, updated, test.updated)
+		}
+		buf, err := ioutil.ReadFile(settings.CacheRoot + linuxPath + ".watch")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if output := string(buf); output != test.expected {
+			t.Errorf("%d: output `%v` != expected `%v`", i, output, test.expected)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/gob"
 	"log"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/mewkiz/pkg/errutil"
@@ -16,6 +17,13 @@ const (
 	QueryClearAll     = "clear all!"
 	QueryForceRecheck = "recheck!"
 	QueryUpdates      = "updates?"
+	QueryErrors       = "errors?"
+	QueryCookies      = "cookies?"
+	QueryCacheNames   = "cache names?"
+
+	// QueryClearCookies is followed by the name of the cookie jar to clear;
+	// no name clears all cookie jars.
+	QueryClearCookies = "clear cookies!"
 )
 
 // Default values.
@@ -26,6 +34,9 @@ const (
 	// Duration until a timeout is issued.
 	TimeoutDuration = 10 * time.Second
 
+	// Default duration until an external filter command is killed.
+	DefaultFilterTimeout = 10 * time.Second
+
 	// Default permissions to create files: user read and write permissions.
 	DefaultFilePerms   = os.FileMode(0600)
 	DefaultFolderPerms = os.FileMode(0755)
@@ -35,6 +46,56 @@ const (
 
 	// Default port number for nyfikenc/d connection.
 	DefaultPortNum = ":5239"
+
+	// Default maximum size of a response body in bytes: 10 MiB.
+	DefaultMaxBodySize = 10 << 20
+
+	// Default number of days before a watched certificate expires to notify
+	// the user.
+	DefaultCertExpiry = 14
+
+	// Duration until a notification is abandoned.
+	NotifyTimeout = 30 * time.Second
+
+	// Duration until snapshots of updated pages are removed.
+	SnapshotMaxAge = 30 * 24 * time.Hour
+
+	// Default number of unchanged lines around the changes in the diffs of
+	// notification mails.
+	DefaultDiffContext = 3
+
+	// Default duration until a connection to the outgoing mail server is
+	// abandoned.
+	DefaultMailTimeout = 30 * time.Second
+
+	// Delay before the first retry of a failed notification; doubled for each
+	// subsequent attempt, up to OutboxMaxBackoff.
+	OutboxBackoff    = 30 * time.Second
+	OutboxMaxBackoff = 1 * time.Hour
+
+	// Duration until a failing notification is abandoned.
+	OutboxMaxAge = 7 * 24 * time.Hour
+)
+
+// HTTP authentication schemes.
+const (
+	AuthBasic  = "basic"
+	AuthBearer = "bearer"
+)
+
+// Security of connections to the outgoing mail server.
+const (
+	SecurityStartTLS = "starttls" // Upgrade the connection with STARTTLS.
+	SecurityTLS      = "tls"      // Implicit TLS, e.g. on port 465.
+	SecurityNone     = "none"     // Unencrypted, e.g. to a local relay.
+)
+
+// SMTP authentication mechanisms.
+const (
+	MailAuthPlain   = "plain"
+	MailAuthLogin   = "login"
+	MailAuthCRAMMD5 = "cram-md5"
+	MailAuthNone    = "none"
 )
 
 // Paths to nyfiken files.
@@ -48,17 +109,35 @@ var (
 	DebugRoot      string
 	DebugCacheRoot string
 	DebugReadRoot  string
+	JarRoot        string
+	SnapshotRoot   string
+	DigestRoot     string
+	OutboxRoot     string
+	SecretsPath    string
+	NetrcPath      string
 )
 
 var (
-	// Updates is a map of all pages which have been updated.
+	// Updates is a map of all pages which have been updated. It's accessed
+	// through SetUpdated, MarkRead, CopyUpdates and ClearUpdates.
 	Updates map[string]bool
 
+	// updatesMutex protects Updates from concurrent checks and notifiers.
+	updatesMutex sync.Mutex
+
+	// Errors is a map of all pages whose last check failed, and the reason.
+	Errors map[string]string
+
+	// errorsMutex protects Errors from concurrent checks.
+	errorsMutex sync.Mutex
+
 	// Settings which will be used unless overwritten by site-specific settings.
 	Global = Prog{
-		Interval:  DefaultInterval,
-		FilePerms: DefaultFilePerms,
-		PortNum:   DefaultPortNum,
+		Interval:    DefaultInterval,
+		FilePerms:   DefaultFilePerms,
+		PortNum:     DefaultPortNum,
+		MaxBodySize: DefaultMaxBodySize,
+		DiffContext: DefaultDiffContext,
 	}
 
 	// When Verbose is true, enable verbose output.
@@ -70,31 +149,110 @@ var (
 type Page struct {
 	Interval   time.Duration     // Duration of time to wait between scrapes.
 	Threshold  float64           // Percentage of accepted deviation from last scrape.
-	RecvMail   string            // Mail address to send a notification when a page has been updated.
+	RecvMail   []string          // Mail addresses to send a notification when a page has been updated.
+	CC         []string          // Mail addresses to send a copy of the notification.
 	Regexp     string            // Regular expression to further specify what to select.
 	Negexp     string            // Everything that matches this regular expression will be removed.
 	StripFuncs []string          // Strip functions to further specify what to select.
 	Header     map[string]string // HTTP headers to request targeted site with.
 	Selection  string            // CSS selector string to specify what to select.
+
+	Filters       []string      // External commands to pipe the selection through.
+	FilterTimeout time.Duration // Duration until a filter command is killed.
+
+	MaxBodySize int64 // Maximum size of a response body in bytes; 0 means no limit.
+
+	Method string // HTTP method to request targeted site with.
+	Body   string // HTTP request body, e.g. an encoded form or JSON.
+
+	Login     *Login // Login recipe to use before checking the page.
+	CookieJar string // Name of the persistent cookie jar; shared by pages using the same name.
+
+	Auth *Auth // HTTP authentication credentials.
+
+	Watch      []string      // Parts of the response to watch for changes, e.g. the status code.
+	CertExpiry time.Duration // Duration before a watched certificate expires to notify the user.
+
+	Notify []string // Names of the notifiers to notify about updates; mail to RecvMail if empty.
+}
+
+// Auth holds the credentials used to authenticate to a site with HTTP Basic or
+// Bearer authentication.
+type Auth struct {
+	Type     string // Authentication scheme; AuthBasic or AuthBearer.
+	User     string // User name of basic authentication.
+	Password string // Password of basic authentication, or token of bearer authentication.
+}
+
+// Login is a recipe describing how to log in to a site before checking its
+// pages. The session cookies are persisted in a cookie jar named after the
+// recipe.
+type Login struct {
+	Name   string            // Name of the recipe and its cookie jar.
+	URL    string            // URL of the page containing the login form.
+	Form   string            // CSS selector of the login form.
+	Fields map[string]string // Form fields to submit, e.g. user name and password.
+	Match  string            // Regular expression matching the URL of the login page.
+}
+
+// Notifier is the configuration of a named notification channel, e.g. a mail
+// address or a webhook.
+type Notifier struct {
+	Name   string              // Name of the notifier.
+	Type   string              // Type of the notifier, e.g. mail.
+	Fields map[string]string   // Fields of the notifier.
+	Lists  map[string][]string // List fields of the notifier.
 }
 
 // Prog is the program global settings which regards all pages unless
 // overwritten with page specific settings.
 type Prog struct {
 	Interval   time.Duration // Duration of time to wait between scrapes.
-	RecvMail   string        // Mail address to send a notification when a page has been updated.
+	RecvMail   []string      // Mail addresses to send a notification when a page has been updated.
 	StripFuncs []string      // Strip functions to further specify what to select.
 	FilePerms  os.FileMode   // Permissions to create files with.
 	PortNum    string        // On which port should the nyfikenc/d communication take place.
 	Browser    string        // The path to the browser to open updates in.
 
+	MaxBodySize int64 // Maximum size of a response body in bytes; 0 means no limit.
+
+	Logins    map[string]*Login    // Named login recipes shared between pages.
+	Notifiers map[string]*Notifier // Named notifiers shared between pages.
+	Groups    map[string][]string  // Named groups of mail addresses.
+
 	// Information about the mail address to send updates.
-	SenderMail struct {
-		Address    string // Mail address of the sending mail.
-		Password   string // Password to that mail address.
-		AuthServer string // Authorization server to the mail address.
-		OutServer  string // Outgoing server to the mail address.
+	SenderMail Sender
+
+	// Go templates of notification mails; the default templates are used if
+	// empty.
+	MailTemplate struct {
+		Subject string // Template of the subject.
+		Text    string // Template of the plain text body.
+		HTML    string // Template of the HTML body.
 	}
+
+	DiffContext int // Number of unchanged lines around the changes in the diffs of notification mails.
+
+	// Schedule of mail digests, e.g. `1h` or `daily 08:00`; empty sends a
+	// mail for each update.
+	Digest string
+}
+
+// Sender is the mail address to send updates from, and how to send them.
+type Sender struct {
+	Address    string // Mail address of the sending mail.
+	Password   string // Password to that mail address.
+	AuthServer string // Authorization server to the mail address.
+	OutServer  string // Outgoing server to the mail address.
+
+	Security string        // Security of the connection; SecurityStartTLS, SecurityTLS or SecurityNone.
+	Auth     string        // Authentication mechanism, e.g. MailAuthPlain.
+	Helo     string        // Host name to greet the outgoing server with; default is localhost.
+	Timeout  time.Duration // Duration until a connection to the outgoing server is abandoned.
+
+	// Path of a sendmail binary which delivers mails instead of the outgoing
+	// server.
+	Sendmail string
 }
 
 // Error wrapper.
@@ -107,6 +265,7 @@ func init() {
 
 func initialize() (err error) {
 	Updates = make(map[string]bool)
+	Errors = make(map[string]string)
 
 	// Will set nyfiken root differently depending on operating system.
 	setNyfikenRoot()
@@ -119,6 +278,18 @@ func initialize() (err error) {
 	DebugRoot = NyfikenRoot + "/debug/"
 	DebugCacheRoot = NyfikenRoot + "/debug/cache/"
 	DebugReadRoot = NyfikenRoot + "/debug/read/"
+	JarRoot = NyfikenRoot + "/jars/"
+	SnapshotRoot = NyfikenRoot + "/snapshots/"
+	DigestRoot = NyfikenRoot + "/digests/"
+	OutboxRoot = NyfikenRoot + "/outbox/"
+	SecretsPath = NyfikenRoot + "/secrets.ini"
+
+	// The netrc file may be moved using the NETRC environment variable, as
+	// supported by curl.
+	NetrcPath = os.Getenv("NETRC")
+	if NetrcPath == "" {
+		NetrcPath = defaultNetrcPath()
+	}
 
 	// Load uncleared updates from last execution.
 	err = LoadUpdates()
@@ -169,11 +340,48 @@ func initialize() (err error) {
 		}
 	}
 
+	if !osutil.Exists(SnapshotRoot) {
+		err := os.Mkdir(SnapshotRoot, DefaultFolderPerms)
+		if err != nil {
+			return errutil.Err(err)
+		}
+	}
+
+	if !osutil.Exists(DigestRoot) {
+		err := os.Mkdir(DigestRoot, DefaultFolderPerms)
+		if err != nil {
+			return errutil.Err(err)
+		}
+	}
+
+	if !osutil.Exists(OutboxRoot) {
+		err := os.Mkdir(OutboxRoot, DefaultFolderPerms)
+		if err != nil {
+			return errutil.Err(err)
+		}
+	}
+
+	// Cookie jars may contain session cookies, so only the user may access
+	// them.
+	if !osutil.Exists(JarRoot) {
+		err := os.Mkdir(JarRoot, 0700)
+		if err != nil {
+			return errutil.Err(err)
+		}
+	}
+
 	return nil
 }
 
 // SaveUpdates saves uncleared updates for next execution.
 func SaveUpdates() (err error) {
+	updatesMutex.Lock()
+	defer updatesMutex.Unlock()
+	return saveUpdates()
+}
+
+// saveUpdates saves the updates; the caller must hold updatesMutex.
+func saveUpdates() (err error) {
 	f, err := os.Create(UpdatesPath)
 	if err != nil {
 		return errutil.Err(err)
@@ -191,6 +399,9 @@ func SaveUpdates() (err error) {
 
 // LoadUpdates retrieves saved updates from last execution.
 func LoadUpdates() (err error) {
+	updatesMutex.Lock()
+	defer updatesMutex.Unlock()
+
 	f, err := os.Open(UpdatesPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -208,3 +419,67 @@ func LoadUpdates() (err error) {
 	}
 	return nil
 }
+
+// SetUpdated adds a page to the list of updates and saves the list.
+func SetUpdated(u string) (err error) {
+	updatesMutex.Lock()
+	defer updatesMutex.Unlock()
+	Updates[u] = true
+	return saveUpdates()
+}
+
+// MarkRead removes pages from the list of updates and saves the list.
+func MarkRead(urls ...string) (err error) {
+	updatesMutex.Lock()
+	defer updatesMutex.Unlock()
+	for _, u := range urls {
+		delete(Updates, u)
+	}
+	return saveUpdates()
+}
+
+// ClearUpdates removes all pages from the list of updates and saves the list.
+func ClearUpdates() (err error) {
+	updatesMutex.Lock()
+	defer updatesMutex.Unlock()
+	Updates = make(map[string]bool)
+	return saveUpdates()
+}
+
+// CopyUpdates returns a copy of Updates which is safe to use concurrently with
+// checks.
+func CopyUpdates() (updates map[string]bool) {
+	updatesMutex.Lock()
+	defer updatesMutex.Unlock()
+	updates = make(map[string]bool)
+	for u := range Updates {
+		updates[u] = true
+	}
+	return updates
+}
+
+// SetError records that the last check of a page failed.
+func SetError(u string, err error) {
+	errorsMutex.Lock()
+	defer errorsMutex.Unlock()
+	Errors[u] = err.Error()
+}
+
+// ClearError records that the last check of a page succeeded.
+func ClearError(u string) {
+	errorsMutex.Lock()
+	defer errorsMutex.Unlock()
+	delete(Errors, u)
+}
+
+// CopyErrors returns a copy of Errors which is safe to use concurrently with
+// checks.
+func CopyErrors() (errs map[string]string) {
+	errorsMutex.Lock()
+	defer errorsMutex.Unlock()
+	errs = make(map[string]string)
+	for u, msg := range Errors {
+		errs[u] = msg
+	}
+	return errs
+}
@@ -7,3 +7,7 @@ import (
 func setNyfikenRoot() {
 	NyfikenRoot = os.Getenv("HOME") + "/.config/nyfiken"
 }
+
+func defaultNetrcPath() string {
+	return os.Getenv("HOME") + "/.netrc"
+}
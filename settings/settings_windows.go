@@ -7,3 +7,7 @@ import (
 func setNyfikenRoot() {
 	NyfikenRoot = os.Getenv("APPDATA") + "/nyfiken"
 }
+
+func defaultNetrcPath() string {
+	return os.Getenv("USERPROFILE") + "/_netrc"
+}
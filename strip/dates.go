@@ -0,0 +1,100 @@
+package strip
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Month names and abbreviations in English, Swedish and German. The English
+// `May` must be capitalized, since `may` is usually a verb.
+const months = `jan(?:uary|uari|uar)?|feb(?:ruary|ruari|ruar)?|mar(?:ch|s)?|märz|apr(?:il)?|maj|(?-i:May)|mai|` +
+	`jun(?:e|i)?|jul(?:y|i)?|aug(?:ust|usti)?|sep(?:t|tember)?|o[ck]t(?:ober)?|nov(?:ember)?|de[cz](?:ember)?`
+
+// Weekday names in English, Swedish and German.
+const weekdays = `(?:mon|tues|wednes|thurs|fri|satur|sun)day|(?:mån|tis|ons|tors|fre|lör|sön)dag(?:en)?|` +
+	`montag|dienstag|mittwoch|donnerstag|freitag|samstag|sonntag`
+
+// dateExps matches dates and times in different formats and locales. The
+// expressions are applied in order, so that longer formats are removed before
+// their parts are. Numeric dates with the day or month first are matched by
+// numericDate.
+var dateExps = []*regexp.Regexp{
+	// ISO 8601 date and time, e.g. `2006-01-02T15:04:05Z`.
+	regexp.MustCompile(`\d{4}-\d{2}-\d{2}(?:[T ]\d{1,2}:\d{2}(?::\d{2}(?:\.\d+)?)?(?:Z|[+-]\d{2}:?\d{2})?)?`),
+	// Numeric dates with the year first, e.g. `2006/01/02`.
+	regexp.MustCompile(`\b\d{4}/\d{1,2}/\d{1,2}\b`),
+	// Day before month, e.g. `2 January 2006`, `2nd of Jan` and `2:e januari`.
+	regexp.MustCompile(`(?i)\b\d{1,2}(?:st|nd|rd|th|:e|:a|\.)?\s+(?:of\s+)?(?:` + months + `)\b\.?(?:,?\s+\d{4}\b)?`),
+	// Month before day, e.g. `January 2, 2006` and `Jan 2nd`.
+	regexp.MustCompile(`(?i)\b(?:` + months + `)\b\.?\s+\d{1,2}(?:st|nd|rd|th)?\b(?:,?\s+\d{4}\b)?`),
+	// Times, e.g. `15:04`, `15:04:05` and `3:04 PM`. Times with a one digit
+	// hour need seconds or AM/PM, so that scores like `3:45` are kept.
+	regexp.MustCompile(`(?i)\b\d{1,2}:[0-5]\d(?::[0-5]\d)?\s*[ap]\.?m\b\.?`),
+	regexp.MustCompile(`\b(?:[01]\d|2[0-3]):[0-5]\d(?::[0-5]\d)?\b`),
+	regexp.MustCompile(`\b\d:[0-5]\d:[0-5]\d\b`),
+	// Weekdays, e.g. `Monday` and `måndag`.
+	regexp.MustCompile(`(?i)\b(?:` + weekdays + `)\b`),
+}
+
+// numericDate matches candidates of numeric dates with the day or month first,
+// e.g. `01/02/2006` and `02.01.06`, which are validated by isNumericDate.
+var numericDate = regexp.MustCompile(`(\d{1,2})([./-])(\d{1,2})([./-])(\d{4}|\d{2})`)
+
+// Dates removes dates, times and weekdays from all text nodes in an html.Node.
+func Dates(doc *html.Node) {
+	texts(doc, func(text string) string {
+		for _, re := range dateExps {
+			text = re.ReplaceAllString(text, "")
+		}
+		return numericDates(text)
+	})
+}
+
+// numericDates removes numeric dates with the day or month first from text.
+func numericDates(text string) string {
+	var buf strings.Builder
+	last := 0
+	for _, m := range numericDate.FindAllStringSubmatchIndex(text, -1) {
+		if !isNumericDate(text, m) {
+			continue
+		}
+		buf.WriteString(text[last:m[0]])
+		last = m[1]
+	}
+	buf.WriteString(text[last:])
+	return buf.String()
+}
+
+// isNumericDate reports whether a match of numericDate in text is a date. The
+// separators must be equal and the day and month within their ranges. Dates
+// with a two digit year must have two digit days and months, and dates may not
+// be part of a longer number, so that version numbers like `1.2.34` are kept.
+func isNumericDate(text string, m []int) bool {
+	if m[0] > 0 && isNumberPart(text[m[0]-1]) {
+		return false
+	}
+	if m[1] < len(text) && isNumberPart(text[m[1]]) {
+		return false
+	}
+	a, sep1, b, sep2, year := text[m[2]:m[3]], text[m[4]:m[5]], text[m[6]:m[7]], text[m[8]:m[9]], text[m[10]:m[11]]
+	if sep1 != sep2 {
+		return false
+	}
+	if len(year) == 2 && (len(a) != 2 || len(b) != 2) {
+		return false
+	}
+	x, _ := strconv.Atoi(a)
+	y, _ := strconv.Atoi(b)
+	dayMonth := x >= 1 && x <= 31 && y >= 1 && y <= 12
+	monthDay := x >= 1 && x <= 12 && y >= 1 && y <= 31
+	return dayMonth || monthDay
+}
+
+// isNumberPart reports whether c may be part of a word or a number, e.g. a
+// version number.
+func isNumberPart(c byte) bool {
+	return c == '.' || c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
@@ -0,0 +1,205 @@
+package strip
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Common tracking query parameters removed by Queries.
+var trackingParams = map[string]bool{
+	"fbclid":  true,
+	"gclid":   true,
+	"dclid":   true,
+	"msclkid": true,
+	"mc_cid":  true,
+	"mc_eid":  true,
+	"_ga":     true,
+}
+
+// Link attributes whose URLs are normalized by Queries.
+var linkAttrs = map[string]bool{
+	"href":   true,
+	"src":    true,
+	"action": true,
+}
+
+// texts replaces the data of all text nodes in an html.Node with the result of
+// f.
+func texts(doc *html.Node, f func(text string) string) {
+	var g func(node *html.Node)
+	g = func(node *html.Node) {
+		if node.Type == html.TextNode {
+			node.Data = f(node.Data)
+		}
+
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			g(c)
+		}
+	}
+	g(doc)
+}
+
+// Whitespace collapses all consecutive whitespace in the text nodes of an
+// html.Node into a single space.
+func Whitespace(doc *html.Node) {
+	texts(doc, func(text string) string {
+		var newSel strings.Builder
+		newSel.Grow(len(text))
+		space := false
+		for _, chr := range text {
+			if unicode.IsSpace(chr) {
+				space = true
+				continue
+			}
+			if space {
+				newSel.WriteByte(' ')
+				space = false
+			}
+			newSel.WriteRune(chr)
+		}
+		if space {
+			newSel.WriteByte(' ')
+		}
+		return newSel.String()
+	})
+}
+
+// Comments removes all HTML comments from an html.Node.
+func Comments(doc *html.Node) {
+	var f func(node *html.Node)
+	f = func(node *html.Node) {
+		for c := node.FirstChild; c != nil; {
+			// Remember the next sibling since it's lost when c is removed.
+			next := c.NextSibling
+			if c.Type == html.CommentNode {
+				node.RemoveChild(c)
+			} else {
+				f(c)
+			}
+			c = next
+		}
+	}
+	f(doc)
+}
+
+// Styles removes all style elements, stylesheet links and inline CSS (style
+// attributes) from an html.Node.
+func Styles(doc *html.Node) {
+	var f func(node *html.Node)
+	f = func(node *html.Node) {
+		for c := node.FirstChild; c != nil; {
+			// Remember the next sibling since it's lost when c is removed.
+			next := c.NextSibling
+			if isStyle(c) {
+				node.RemoveChild(c)
+			} else {
+				f(c)
+			}
+			c = next
+		}
+
+		if node.Type != html.ElementNode {
+			return
+		}
+		var attrs []html.Attribute
+		for _, attr := range node.Attr {
+			if strings.ToLower(attr.Key) != "style" {
+				attrs = append(attrs, attr)
+			}
+		}
+		node.Attr = attrs
+	}
+	f(doc)
+}
+
+// isStyle reports whether the node is a style element or a stylesheet link.
+func isStyle(node *html.Node) bool {
+	if node.Type != html.ElementNode {
+		return false
+	}
+	switch node.Data {
+	case "style":
+		return true
+	case "link":
+		for _, attr := range node.Attr {
+			if strings.ToLower(attr.Key) == "rel" && strings.Contains(strings.ToLower(attr.Val), "stylesheet") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Queries normalizes the query strings of all links in an html.Node. Query
+// parameters are sorted, and tracking parameters (e.g. utm_source and fbclid)
+// and the parameters listed in params are removed.
+func Queries(doc *html.Node, params ...string) {
+	ignore := make(map[string]bool)
+	for _, param := range params {
+		ignore[strings.ToLower(param)] = true
+	}
+
+	var f func(node *html.Node)
+	f = func(node *html.Node) {
+		if node.Type == html.ElementNode {
+			for i, attr := range node.Attr {
+				if linkAttrs[strings.ToLower(attr.Key)] {
+					node.Attr[i].Val = normalizeQuery(attr.Val, ignore)
+				}
+			}
+		}
+
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+}
+
+// normalizeQuery sorts the query parameters of rawurl and removes tracking
+// parameters and parameters present in ignore. Unparsable URLs are returned
+// unmodified.
+func normalizeQuery(rawurl string, ignore map[string]bool) string {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.RawQuery == "" {
+		return rawurl
+	}
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return rawurl
+	}
+	for key := range query {
+		lower := strings.ToLower(key)
+		if trackingParams[lower] || strings.HasPrefix(lower, "utm_") || ignore[lower] {
+			delete(query, key)
+		}
+	}
+	for _, vals := range query {
+		sort.Strings(vals)
+	}
+	// Encode sorts the parameters by key.
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// NFKC applies Unicode compatibility normalization (NFKC) to all text nodes in
+// an html.Node, e.g. full-width characters and ligatures are replaced by their
+// canonical counterparts.
+func NFKC(doc *html.Node) {
+	texts(doc, norm.NFKC.String)
+}
+
+// CaseFold applies Unicode case folding to all text nodes in an html.Node,
+// making the comparison case insensitive.
+func CaseFold(doc *html.Node) {
+	fold := cases.Fold()
+	texts(doc, func(text string) string {
+		return fold.String(text)
+	})
+}
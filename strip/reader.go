@@ -0,0 +1,306 @@
+package strip
+
+import (
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/karlek/nyfiken/settings"
+	"golang.org/x/net/html"
+)
+
+// The reader extraction is a simplified version of the readability heuristic:
+// paragraphs award points to their ancestors depending on their length and
+// number of commas, and the ancestor with the highest score, adjusted for its
+// link density, is considered the main content of the page.
+
+// Class and id patterns of elements which are unlikely or likely to contain
+// the main content of a page.
+var (
+	unlikelyExp = regexp.MustCompile(`(?i)banner|breadcrumb|combx|comment|community|cookie|disqus|extra|foot|header|legends|menu|modal|nav|pagination|pager|popup|related|remark|rss|share|shoutbox|sidebar|skyscraper|social|sponsor|ad-break|agegate|promo|widget`)
+	likelyExp   = regexp.MustCompile(`(?i)article|body|column|content|main|shadow`)
+	negativeExp = regexp.MustCompile(`(?i)hidden|banner|combx|comment|com-|contact|foot|footer|footnote|masthead|media|meta|outbrain|promo|related|scroll|share|shoutbox|sidebar|skyscraper|sponsor|shopping|tags|tool|widget`)
+	positiveExp = regexp.MustCompile(`(?i)article|body|content|entry|hentry|h-entry|main|page|post|text|blog|story`)
+)
+
+// Elements which never contain readable content. Forms are only scored down,
+// since some sites wrap the whole page in one, e.g. ASP.NET.
+var unreadableTags = map[string]bool{
+	"aside":    true,
+	"button":   true,
+	"footer":   true,
+	"header":   true,
+	"iframe":   true,
+	"nav":      true,
+	"noscript": true,
+	"object":   true,
+	"script":   true,
+	"select":   true,
+	"style":    true,
+	"svg":      true,
+	"template": true,
+}
+
+// Elements whose text content is extracted as a single block.
+var readableTags = map[string]bool{
+	"blockquote": true,
+	"dd":         true,
+	"dt":         true,
+	"figcaption": true,
+	"h1":         true,
+	"h2":         true,
+	"h3":         true,
+	"h4":         true,
+	"h5":         true,
+	"h6":         true,
+	"li":         true,
+	"p":          true,
+	"pre":        true,
+}
+
+// Elements which separate loose text into different paragraphs.
+var blockTags = map[string]bool{
+	"article": true,
+	"br":      true,
+	"div":     true,
+	"hr":      true,
+	"main":    true,
+	"ol":      true,
+	"section": true,
+	"table":   true,
+	"td":      true,
+	"tr":      true,
+	"ul":      true,
+}
+
+// Minimum length of a paragraph to be scored.
+const minParagraphLen = 25
+
+// Reader replaces an html.Node with the paragraphs of its main content, e.g.
+// the text of an article without navigation, sidebars and footers.
+func Reader(doc *html.Node) {
+	prune(doc)
+
+	top := topCandidate(doc)
+	if top == nil {
+		top = doc
+	}
+
+	var newSel string
+	for _, b := range readBlocks(top) {
+		newSel += "<" + b.tag + ">" + html.EscapeString(b.text) + "</" + b.tag + ">" + settings.Newline
+	}
+
+	/// Check for errors
+	stringNode, _ := html.Parse(strings.NewReader(newSel))
+	*doc = *stringNode
+}
+
+// prune removes elements from an html.Node which are unlikely to contain the
+// main content.
+func prune(node *html.Node) {
+	for c := node.FirstChild; c != nil; {
+		// Remember the next sibling since it's lost when c is removed.
+		next := c.NextSibling
+		switch {
+		case c.Type == html.CommentNode:
+			node.RemoveChild(c)
+		case c.Type == html.ElementNode && isUnlikely(c):
+			node.RemoveChild(c)
+		default:
+			prune(c)
+		}
+		c = next
+	}
+}
+
+// isUnlikely reports whether an element is unlikely to contain the main
+// content.
+func isUnlikely(node *html.Node) bool {
+	if unreadableTags[node.Data] {
+		return true
+	}
+	if node.Data == "body" || node.Data == "html" || node.Data == "article" || node.Data == "main" {
+		return false
+	}
+	match := attr(node, "class") + " " + attr(node, "id")
+	return unlikelyExp.MatchString(match) && !likelyExp.MatchString(match)
+}
+
+// topCandidate returns the element most likely to contain the main content, or
+// nil if no paragraph long enough was found.
+func topCandidate(doc *html.Node) *html.Node {
+	scores := make(map[*html.Node]float64)
+	var candidates []*html.Node
+
+	// initScore adds the node to the candidates.
+	initScore := func(node *html.Node) {
+		if _, found := scores[node]; found {
+			return
+		}
+		scores[node] = tagWeight(node) + classWeight(node)
+		candidates = append(candidates, node)
+	}
+
+	var f func(node *html.Node)
+	f = func(node *html.Node) {
+		if node.Type == html.ElementNode && (node.Data == "p" || node.Data == "pre" || node.Data == "td") {
+			text := textContent(node)
+			if len(text) >= minParagraphLen && node.Parent != nil && node.Parent.Type == html.ElementNode {
+				// One point for the paragraph itself, one per comma and one per
+				// 100 characters, up to 3.
+				score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text)/100), 3)
+
+				parent := node.Parent
+				initScore(parent)
+				scores[parent] += score
+				if grand := parent.Parent; grand != nil && grand.Type == html.ElementNode {
+					initScore(grand)
+					scores[grand] += score / 2
+				}
+			}
+		}
+
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(doc)
+
+	var top *html.Node
+	var topScore float64
+	for _, node := range candidates {
+		// Scale the score by the link density, since navigation blocks mostly
+		// consist of links.
+		score := scores[node] * (1 - linkDensity(node))
+		if top == nil || score > topScore {
+			top = node
+			topScore = score
+		}
+	}
+	return top
+}
+
+// tagWeight returns the initial score of a candidate based on its tag name.
+func tagWeight(node *html.Node) float64 {
+	switch node.Data {
+	case "article", "main":
+		return 10
+	case "div":
+		return 5
+	case "pre", "td", "blockquote":
+		return 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+		return -3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		return -5
+	}
+	return 0
+}
+
+// classWeight returns a score based on the class and id of an element.
+func classWeight(node *html.Node) (weight float64) {
+	for _, val := range []string{attr(node, "class"), attr(node, "id")} {
+		if val == "" {
+			continue
+		}
+		if negativeExp.MatchString(val) {
+			weight -= 25
+		}
+		if positiveExp.MatchString(val) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+// linkDensity returns the ratio of link text to all text in a node.
+func linkDensity(node *html.Node) float64 {
+	textLen := len(textContent(node))
+	if textLen == 0 {
+		return 0
+	}
+	var linkLen int
+	var f func(node *html.Node)
+	f = func(node *html.Node) {
+		if node.Type == html.ElementNode && node.Data == "a" {
+			linkLen += len(textContent(node))
+			return
+		}
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(node)
+	return float64(linkLen) / float64(textLen)
+}
+
+// A block is a paragraph of readable text.
+type block struct {
+	tag  string // Tag name of the block, e.g. p or h1.
+	text string // Text of the block with collapsed whitespace.
+}
+
+// readBlocks returns the readable blocks of an html.Node. Loose text which is
+// not contained in a readable element is grouped into paragraphs.
+func readBlocks(node *html.Node) (blocks []block) {
+	var loose string
+	flush := func() {
+		if text := strings.Join(strings.Fields(loose), " "); text != "" {
+			blocks = append(blocks, block{"p", text})
+		}
+		loose = ""
+	}
+
+	var f func(node *html.Node)
+	f = func(node *html.Node) {
+		switch node.Type {
+		case html.TextNode:
+			loose += node.Data
+			return
+		case html.ElementNode:
+			if readableTags[node.Data] {
+				flush()
+				text := strings.Join(strings.Fields(textContent(node)), " ")
+				// Skip empty blocks and lists of links.
+				if text != "" && linkDensity(node) < 0.5 {
+					blocks = append(blocks, block{node.Data, text})
+				}
+				return
+			}
+			if blockTags[node.Data] {
+				flush()
+				defer flush()
+			}
+		}
+
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
+		}
+	}
+	f(node)
+	flush()
+	return blocks
+}
+
+// textContent returns the concatenated text of all text nodes in a node.
+func textContent(node *html.Node) string {
+	if node.Type == html.TextNode {
+		return node.Data
+	}
+	var text string
+	for c := node.FirstChild; c != nil; c = c.NextSibling {
+		text += textContent(c)
+	}
+	return text
+}
+
+// attr returns the value of the attribute key of a node.
+func attr(node *html.Node, key string) string {
+	for _, a := range node.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
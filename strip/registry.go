@@ -0,0 +1,148 @@
+package strip
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/mewkiz/pkg/errutil"
+	"golang.org/x/net/html"
+)
+
+// ArgSep separates the name of a strip function from its argument, e.g.
+// `remove:script,style`.
+const ArgSep = ":"
+
+// A Func removes false positives from an html.Node.
+type Func func(doc *html.Node)
+
+// A Parser parses the argument of a strip function declaration and returns the
+// strip function to use. The argument is empty if none was given.
+type Parser func(arg string) (Func, error)
+
+// Error messages.
+var (
+	errUnknownFunc = "strip: unknown function `%s`."
+	errNoArg       = "strip: function `%s` takes no argument; got `%s`."
+	errMissingArg  = "strip: function `%s` requires an argument."
+)
+
+var (
+	// mu protects parsers.
+	mu sync.RWMutex
+
+	// parsers maps the names of strip functions to their argument parsers.
+	parsers = make(map[string]Parser)
+)
+
+// Built-in strip functions.
+func init() {
+	RegisterFunc("html", HTML)
+	RegisterFunc("attrs", Attrs)
+	RegisterFunc("numbers", Numbers)
+	RegisterFunc("scripts", Scripts)
+	RegisterFunc("whitespace", Whitespace)
+	RegisterFunc("comments", Comments)
+	RegisterFunc("styles", Styles)
+	RegisterFunc("dates", Dates)
+	RegisterFunc("nfkc", NFKC)
+	RegisterFunc("casefold", CaseFold)
+	RegisterFunc("reader", Reader)
+	Register("remove", parseRemove)
+	Register("queries", parseQueries)
+}
+
+// Register makes a strip function available by the provided name, which may
+// then be used in the strip list of a page. The parser is called with the
+// argument of each declaration using the name. Register panics if name is
+// empty, contains ArgSep, or if a strip function by the same name has already
+// been registered.
+func Register(name string, parse Parser) {
+	name = strings.ToLower(name)
+	if name == "" || strings.Contains(name, ArgSep) {
+		panic("strip: invalid function name `" + name + "`")
+	}
+	if parse == nil {
+		panic("strip: nil parser for function `" + name + "`")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if _, found := parsers[name]; found {
+		panic("strip: function `" + name + "` registered twice")
+	}
+	parsers[name] = parse
+}
+
+// RegisterFunc registers a strip function which takes no argument.
+func RegisterFunc(name string, f Func) {
+	Register(name, func(arg string) (Func, error) {
+		if arg != "" {
+			return nil, errutil.NewNoPosf(errNoArg, name, arg)
+		}
+		return f, nil
+	})
+}
+
+// Parse parses a strip function declaration (e.g. `numbers` or
+// `remove:script,.ad`) and returns the registered strip function.
+func Parse(decl string) (f Func, err error) {
+	name, arg := Split(decl)
+
+	mu.RLock()
+	parse, found := parsers[name]
+	mu.RUnlock()
+	if !found {
+		return nil, errutil.NewNoPosf(errUnknownFunc, name)
+	}
+
+	return parse(arg)
+}
+
+// Names returns the sorted names of all registered strip functions.
+func Names() (names []string) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for name := range parsers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Split splits a strip function declaration into its lower case name and its
+// argument. The argument is empty if none was given.
+func Split(decl string) (name, arg string) {
+	if i := strings.Index(decl, ArgSep); i != -1 {
+		return strings.ToLower(strings.TrimSpace(decl[:i])), strings.TrimSpace(decl[i+len(ArgSep):])
+	}
+	return strings.ToLower(strings.TrimSpace(decl)), ""
+}
+
+// parseRemove parses a comma separated list of tag names and CSS selectors for
+// Remove.
+func parseRemove(arg string) (f Func, err error) {
+	if arg == "" {
+		return nil, errutil.NewNoPosf(errMissingArg, "remove")
+	}
+	sel, err := cascadia.Compile(arg)
+	if err != nil {
+		return nil, errutil.Err(err)
+	}
+	return func(doc *html.Node) {
+		Remove(doc, sel)
+	}, nil
+}
+
+// parseQueries parses an optional comma separated list of query parameters to
+// remove for Queries.
+func parseQueries(arg string) (f Func, err error) {
+	var params []string
+	if arg != "" {
+		params = strings.Split(arg, ",")
+	}
+	return func(doc *html.Node) {
+		Queries(doc, params...)
+	}, nil
+}
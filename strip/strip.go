@@ -10,6 +10,7 @@ import (
 	"strings"
 	"unicode"
 
+	"github.com/andybalholm/cascadia"
 	"github.com/karlek/nyfiken/settings"
 	"golang.org/x/net/html"
 )
@@ -52,18 +53,20 @@ func Attrs(doc *html.Node) {
 
 // Scripts removes all script elements from an html.Node.
 func Scripts(doc *html.Node) {
-	var f func(node *html.Node)
-	f = func(node *html.Node) {
-		if node.Type == html.ElementNode && node.Data == "script" {
-			node = nil
-			return
-		}
+	Remove(doc, cascadia.MustCompile("script"))
+}
 
-		for c := node.FirstChild; c != nil; c = c.NextSibling {
-			f(c)
+// Remove removes all elements matching the selector sel from an html.Node.
+// Since CSS selector groups are comma separated, sel may be compiled from a
+// list of tag names and CSS selectors, e.g. `script,style,noscript,.ad`.
+func Remove(doc *html.Node, sel cascadia.Selector) {
+	// Collect all matches before modifying the tree, since removing a node
+	// while walking it would cut the walk short.
+	for _, node := range sel.MatchAll(doc) {
+		if node.Parent != nil {
+			node.Parent.RemoveChild(node)
 		}
 	}
-	f(doc)
 }
 
 // HTML removes HTML tags from an html.Node and leaves the text.
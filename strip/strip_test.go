@@ -4,7 +4,9 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/andybalholm/cascadia"
 	"github.com/karlek/nyfiken/settings"
+	"github.com/mewkiz/pkg/htmlutil"
 	"golang.org/x/net/html"
 )
 
@@ -22,7 +24,11 @@ func TestNumber(t *testing.T) {
 	}
 
 	for _, test := range testTable {
-		numFree := Numbers(test.input)
+		Numbers(test.input)
+		numFree, err := htmlutil.RenderClean(test.input)
+		if err != nil {
+			t.Errorf("error: %s", err)
+		}
 		if numFree != test.output {
 			t.Errorf("output `%v` != expected `%v`", numFree, test.output)
 		}
@@ -43,7 +49,11 @@ func TestAttrs(t *testing.T) {
 	}
 
 	for _, test := range testTable {
-		numFree := Attrs(test.input)
+		Attrs(test.input)
+		numFree, err := htmlutil.RenderClean(test.input)
+		if err != nil {
+			t.Errorf("error: %s", err)
+		}
 		if numFree != test.output {
 			t.Errorf("output `%v` != expected `%v`", numFree, test.output)
 		}
@@ -60,13 +70,394 @@ func TestHTML(t *testing.T) {
 		input  *html.Node
 		output string
 	}{
-		{node1, `HTML test` + settings.Newline + `I am red!` + settings.Newline},
+		{node1, `<html><head></head><body>HTML test` + settings.Newline + `I am red!` + settings.Newline + `</body></html>`},
 	}
 
 	for _, test := range testTable {
-		numFree := HTML(test.input)
+		HTML(test.input)
+		numFree, err := htmlutil.RenderClean(test.input)
+		if err != nil {
+			t.Errorf("error: %s", err)
+		}
 		if numFree != test.output {
 			t.Errorf("output `%v` != expected `%v`", numFree, test.output)
 		}
 	}
 }
+
+func TestScripts(t *testing.T) {
+	node1, err := html.Parse(strings.NewReader(`<html><head><title>Script test</title><script>var i = 0;</script></head><body><b>Not a script</b><script src="ad.js"></script></body></html>`))
+	if err != nil {
+		t.Errorf("error: %s", err)
+	}
+
+	var testTable = []struct {
+		input  *html.Node
+		output string
+	}{
+		{node1, `<html><head><title>Script test</title></head><body><b>Not a script</b></body></html>`},
+	}
+
+	for _, test := range testTable {
+		Scripts(test.input)
+		scriptFree, err := htmlutil.RenderClean(test.input)
+		if err != nil {
+			t.Errorf("error: %s", err)
+		}
+		if scriptFree != test.output {
+			t.Errorf("output `%v` != expected `%v`", scriptFree, test.output)
+		}
+	}
+}
+
+func TestRemove(t *testing.T) {
+	node1, err := html.Parse(strings.NewReader(`<html><head><style>b {}</style></head><body><b>Keep</b><div class="ad"><p>Buy!</p></div><noscript>No JS</noscript></body></html>`))
+	if err != nil {
+		t.Errorf("error: %s", err)
+	}
+
+	var testTable = []struct {
+		input  *html.Node
+		sel    string
+		output string
+	}{
+		{node1, "script,style,noscript,.ad", `<html><head></head><body><b>Keep</b></body></html>`},
+	}
+
+	for _, test := range testTable {
+		Remove(test.input, cascadia.MustCompile(test.sel))
+		removed, err := htmlutil.RenderClean(test.input)
+		if err != nil {
+			t.Errorf("error: %s", err)
+		}
+		if removed != test.output {
+			t.Errorf("output `%v` != expected `%v`", removed, test.output)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	var testTable = []struct {
+		decl string
+		name string
+		arg  string
+	}{
+		{"html", "html", ""},
+		{"Remove:script,.Ad", "remove", "script,.Ad"},
+		{"remove: a:hover", "remove", "a:hover"},
+	}
+
+	for _, test := range testTable {
+		name, arg := Split(test.decl)
+		if name != test.name || arg != test.arg {
+			t.Errorf("output `%v`, `%v` != expected `%v`, `%v`", name, arg, test.name, test.arg)
+		}
+	}
+}
+
+func TestWhitespace(t *testing.T) {
+	node1, err := html.Parse(strings.NewReader("<html><head><title>Whitespace \t test</title></head><body><b>\n\n  I am  spacious  </b></body></html>"))
+	if err != nil {
+		t.Errorf("error: %s", err)
+	}
+
+	var testTable = []struct {
+		input  *html.Node
+		output string
+	}{
+		{node1, `<html><head><title>Whitespace test</title></head><body><b> I am spacious </b></body></html>`},
+	}
+
+	for _, test := range testTable {
+		Whitespace(test.input)
+		collapsed, err := htmlutil.RenderClean(test.input)
+		if err != nil {
+			t.Errorf("error: %s", err)
+		}
+		if collapsed != test.output {
+			t.Errorf("output `%v` != expected `%v`", collapsed, test.output)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	node1, err := html.Parse(strings.NewReader(`<html><head><!-- generated in 0.12s --><title>Comment test</title></head><body><b>Visible</b><!-- cache: hit --></body></html>`))
+	if err != nil {
+		t.Errorf("error: %s", err)
+	}
+
+	var testTable = []struct {
+		input  *html.Node
+		output string
+	}{
+		{node1, `<html><head><title>Comment test</title></head><body><b>Visible</b></body></html>`},
+	}
+
+	for _, test := range testTable {
+		Comments(test.input)
+		commentFree, err := htmlutil.RenderClean(test.input)
+		if err != nil {
+			t.Errorf("error: %s", err)
+		}
+		if commentFree != test.output {
+			t.Errorf("output `%v` != expected `%v`", commentFree, test.output)
+		}
+	}
+}
+
+func TestStyles(t *testing.T) {
+	node1, err := html.Parse(strings.NewReader(`<html><head><title>Style test</title><link rel="stylesheet" href="a.css"><link rel="icon" href="a.ico"><style>b {}</style></head><body><b class="x" style="color: #f00;">I am red!</b></body></html>`))
+	if err != nil {
+		t.Errorf("error: %s", err)
+	}
+
+	var testTable = []struct {
+		input  *html.Node
+		output string
+	}{
+		{node1, `<html><head><title>Style test</title><link rel="icon" href="a.ico"/></head><body><b class="x">I am red!</b></body></html>`},
+	}
+
+	for _, test := range testTable {
+		Styles(test.input)
+		styleFree, err := htmlutil.RenderClean(test.input)
+		if err != nil {
+			t.Errorf("error: %s", err)
+		}
+		if styleFree != test.output {
+			t.Errorf("output `%v` != expected `%v`", styleFree, test.output)
+		}
+	}
+}
+
+func TestDates(t *testing.T) {
+	var testTable = []struct {
+		input  string
+		output string
+	}{
+		{"Posted 2013-02-17T13:37:00Z.", "Posted ."},
+		{"Posted 2013-02-17 by me", "Posted  by me"},
+		{"Due 02/17/2013 or 17.02.13", "Due  or "},
+		{"On 17 February 2013 at 13:37", "On  at "},
+		{"On February 17th, 2013 at 1:37 PM", "On  at "},
+		{"Sunday, 2 Feb", ", "},
+		{"Uppdaterad måndag 17 februari 2013 kl. 13:37", "Uppdaterad   kl. "},
+		{"Am 17. März 2013", "Am "},
+		{"Version 1.2.3 costs 20", "Version 1.2.3 costs 20"},
+		{"Released May 5, 2013", "Released "},
+		{"Version 1.2.34", "Version 1.2.34"},
+		{"Version 10.11.12.13", "Version 10.11.12.13"},
+		{"Since 2.1.2013", "Since "},
+		{"Final score 3:45", "Final score 3:45"},
+		{"You may 5 times", "You may 5 times"},
+	}
+
+	for _, test := range testTable {
+		node, err := html.Parse(strings.NewReader(test.input))
+		if err != nil {
+			t.Errorf("error: %s", err)
+		}
+		Dates(node)
+		dateFree, err := htmlutil.RenderClean(node)
+		if err != nil {
+			t.Errorf("error: %s", err)
+		}
+		expected := `<html><head></head><body>` + test.output + `</body></html>`
+		if dateFree != expected {
+			t.Errorf("output `%v` != expected `%v`", dateFree, expected)
+		}
+	}
+}
+
+func TestQueries(t *testing.T) {
+	node1, err := html.Parse(strings.NewReader(`<html><head></head><body><a href="/p?b=2&amp;utm_source=x&amp;a=1&amp;sid=42">Link</a><img src="i.png?fbclid=1"/><a href="#top">Top</a></body></html>`))
+	if err != nil {
+		t.Errorf("error: %s", err)
+	}
+
+	var testTable = []struct {
+		input  *html.Node
+		params []string
+		output string
+	}{
+		{node1, []string{"SID"}, `<html><head></head><body><a href="/p?a=1&amp;b=2">Link</a><img src="i.png"/><a href="#top">Top</a></body></html>`},
+	}
+
+	for _, test := range testTable {
+		Queries(test.input, test.params...)
+		normalized, err := htmlutil.RenderClean(test.input)
+		if err != nil {
+			t.Errorf("error: %s", err)
+		}
+		if normalized != test.output {
+			t.Errorf("output `%v` != expected `%v`", normalized, test.output)
+		}
+	}
+}
+
+func TestNFKC(t *testing.T) {
+	node1, err := html.Parse(strings.NewReader("<html><head></head><body><b>ＡＢＣ ﬁle ①</b></body></html>"))
+	if err != nil {
+		t.Errorf("error: %s", err)
+	}
+
+	var testTable = []struct {
+		input  *html.Node
+		output string
+	}{
+		{node1, `<html><head></head><body><b>ABC file 1</b></body></html>`},
+	}
+
+	for _, test := range testTable {
+		NFKC(test.input)
+		normalized, err := htmlutil.RenderClean(test.input)
+		if err != nil {
+			t.Errorf("error: %s", err)
+		}
+		if normalized != test.output {
+			t.Errorf("output `%v` != expected `%v`", normalized, test.output)
+		}
+	}
+}
+
+func TestCaseFold(t *testing.T) {
+	node1, err := html.Parse(strings.NewReader(`<html><head></head><body><B>Straße IS ÖPPEN</B></body></html>`))
+	if err != nil {
+		t.Errorf("error: %s", err)
+	}
+
+	var testTable = []struct {
+		input  *html.Node
+		output string
+	}{
+		{node1, `<html><head></head><body><b>strasse is öppen</b></body></html>`},
+	}
+
+	for _, test := range testTable {
+		CaseFold(test.input)
+		folded, err := htmlutil.RenderClean(test.input)
+		if err != nil {
+			t.Errorf("error: %s", err)
+		}
+		if folded != test.output {
+			t.Errorf("output `%v` != expected `%v`", folded, test.output)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	var testTable = []struct {
+		decl  string
+		valid bool
+	}{
+		{"html", true},
+		{"NUMBERS", true},
+		{"remove:script,.ad", true},
+		{"queries", true},
+		{"queries:sid", true},
+		{"remove", false},
+		{"remove:[", false},
+		{"html:p", false},
+		{"unknown", false},
+	}
+
+	for _, test := range testTable {
+		f, err := Parse(test.decl)
+		if test.valid && (err != nil || f == nil) {
+			t.Errorf("%s: unexpected error: %v", test.decl, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected error", test.decl)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("prefix", func(arg string) (Func, error) {
+		return func(doc *html.Node) {
+			texts(doc, func(text string) string {
+				return arg + text
+			})
+		}, nil
+	})
+
+	node1, err := html.Parse(strings.NewReader(`<html><head></head><body><b>text</b></body></html>`))
+	if err != nil {
+		t.Errorf("error: %s", err)
+	}
+	f, err := Parse("Prefix:my-")
+	if err != nil {
+		t.Fatalf("error: %s", err)
+	}
+	f(node1)
+	output, err := htmlutil.RenderClean(node1)
+	if err != nil {
+		t.Errorf("error: %s", err)
+	}
+	expected := `<html><head></head><body><b>my-text</b></body></html>`
+	if output != expected {
+		t.Errorf("output `%v` != expected `%v`", output, expected)
+	}
+
+	found := false
+	for _, name := range Names() {
+		if name == "prefix" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("registered function `prefix` not in %v", Names())
+	}
+}
+
+func TestReader(t *testing.T) {
+	node1, err := html.Parse(strings.NewReader(`<html><head><title>Reader test</title></head><body>
+<nav><a href="/">Home</a> <a href="/news">News</a></nav>
+<div id="sidebar"><p>Subscribe to our newsletter, it is free, fun and full of ads.</p></div>
+<div class="article-content">
+	<h1>Nyfiken released</h1>
+	<p>Nyfiken checks pages for updates, and notifies the user when they change.</p>
+	<p>The  reader mode keeps the
+	main content, and skips navigation and footers.</p>
+	<ul><li><a href="/a">Related article one</a></li><li><a href="/b">Related article two</a></li></ul>
+</div>
+<footer><p>Copyright, all rights reserved, no matter what you think.</p></footer>
+</body></html>`))
+	if err != nil {
+		t.Errorf("error: %s", err)
+	}
+
+	// ASP.NET pages are wrapped in a form.
+	node2, err := html.Parse(strings.NewReader(`<html><body><form id="aspnetForm" method="post" action="default.aspx">
+<nav><a href="/">Home</a></nav>
+<div class="content">
+	<p>Nyfiken checks pages for updates, and notifies the user when they change.</p>
+</div>
+</form></body></html>`))
+	if err != nil {
+		t.Errorf("error: %s", err)
+	}
+
+	var testTable = []struct {
+		input  *html.Node
+		output string
+	}{
+		{node1, `<html><head></head><body><h1>Nyfiken released</h1>` + settings.Newline +
+			`<p>Nyfiken checks pages for updates, and notifies the user when they change.</p>` + settings.Newline +
+			`<p>The reader mode keeps the main content, and skips navigation and footers.</p>` + settings.Newline +
+			`</body></html>`},
+		{node2, `<html><head></head><body><p>Nyfiken checks pages for updates, and notifies the user when they change.</p>` + settings.Newline +
+			`</body></html>`},
+	}
+
+	for _, test := range testTable {
+		Reader(test.input)
+		readable, err := htmlutil.RenderClean(test.input)
+		if err != nil {
+			t.Errorf("error: %s", err)
+		}
+		if readable != test.output {
+			t.Errorf("output `%v` != expected `%v`", readable, test.output)
+		}
+	}
+}